@@ -31,6 +31,7 @@ type App struct {
 	configManager     types.ConfigManager
 	settingsManager   *config.SystemSettingsManager
 	groupManager      *services.GroupManager
+	proxyKeyService   *services.ProxyKeyService
 	logCleanupService *services.LogCleanupService
 	requestLogService *services.RequestLogService
 	cronChecker       *keypool.CronChecker
@@ -48,6 +49,7 @@ type AppParams struct {
 	ConfigManager     types.ConfigManager
 	SettingsManager   *config.SystemSettingsManager
 	GroupManager      *services.GroupManager
+	ProxyKeyService   *services.ProxyKeyService
 	LogCleanupService *services.LogCleanupService
 	RequestLogService *services.RequestLogService
 	CronChecker       *keypool.CronChecker
@@ -64,6 +66,7 @@ func NewApp(params AppParams) *App {
 		configManager:     params.ConfigManager,
 		settingsManager:   params.SettingsManager,
 		groupManager:      params.GroupManager,
+		proxyKeyService:   params.ProxyKeyService,
 		logCleanupService: params.LogCleanupService,
 		requestLogService: params.RequestLogService,
 		cronChecker:       params.CronChecker,
@@ -97,8 +100,11 @@ func (a *App) Start() error {
 			&models.Group{},
 			&models.GroupSubGroup{},
 			&models.APIKey{},
+			&models.ProxyKey{},
 			&models.RequestLog{},
 			&models.GroupHourlyStat{},
+			&models.GroupModelHourlyStat{},
+			&models.ProxyKeyDailyUsage{},
 		); err != nil {
 			return fmt.Errorf("database auto-migration failed: %w", err)
 		}
@@ -116,6 +122,11 @@ func (a *App) Start() error {
 
 		a.settingsManager.Initialize(a.storage, a.groupManager, a.configManager.IsMaster())
 
+		// 导入旧版逗号分隔的代理密钥
+		if err := a.proxyKeyService.MigrateLegacyKeys(); err != nil {
+			return fmt.Errorf("failed to migrate legacy proxy keys: %w", err)
+		}
+
 		// 从数据库加载密钥到 Redis
 		if err := a.keyPoolProvider.LoadKeysFromDB(); err != nil {
 			return fmt.Errorf("failed to load keys into key pool: %w", err)
@@ -135,6 +146,10 @@ func (a *App) Start() error {
 	a.configManager.DisplayServerConfig()
 
 	a.groupManager.Initialize()
+	a.keyPoolProvider.Start()
+	if err := a.proxyKeyService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize proxy key cache: %w", err)
+	}
 
 	// Create HTTP server
 	serverConfig := a.configManager.GetEffectiveServerConfig()
@@ -184,6 +199,8 @@ func (a *App) Stop(ctx context.Context) {
 	// 使用原始的总超时 context 继续关闭其他后台服务
 	stoppableServices := []func(context.Context){
 		a.groupManager.Stop,
+		a.proxyKeyService.Stop,
+		a.keyPoolProvider.Stop,
 		a.settingsManager.Stop,
 	}
 
@@ -78,6 +78,7 @@ func (m *Manager) ReloadConfig() error {
 			WriteTimeout:            utils.ParseInteger(os.Getenv("SERVER_WRITE_TIMEOUT"), 600),
 			IdleTimeout:             utils.ParseInteger(os.Getenv("SERVER_IDLE_TIMEOUT"), 120),
 			GracefulShutdownTimeout: utils.ParseInteger(os.Getenv("SERVER_GRACEFUL_SHUTDOWN_TIMEOUT"), 10),
+			TrustedProxies:          utils.ParseArray(os.Getenv("TRUSTED_PROXIES"), []string{}),
 		},
 		Auth: types.AuthConfig{
 			Key: os.Getenv("AUTH_KEY"),
@@ -185,6 +186,10 @@ func (m *Manager) Validate() error {
 		m.config.Server.GracefulShutdownTimeout = 10
 	}
 
+	if _, err := utils.ParseCIDRList(strings.Join(m.config.Server.TrustedProxies, ",")); err != nil {
+		validationErrors = append(validationErrors, fmt.Sprintf("TRUSTED_PROXIES is invalid: %v", err))
+	}
+
 	if m.config.CORS.Enabled {
 		if len(m.config.CORS.AllowedOrigins) == 0 {
 			validationErrors = append(validationErrors, "CORS is enabled but ALLOWED_ORIGINS is not set. UI will not work from a browser.")
@@ -238,6 +243,11 @@ func (m *Manager) DisplayServerConfig() {
 		corsStatus = fmt.Sprintf("enabled (Origins: %s)", strings.Join(corsConfig.AllowedOrigins, ", "))
 	}
 	logrus.Infof("    CORS: %s", corsStatus)
+	if len(serverConfig.TrustedProxies) > 0 {
+		logrus.Infof("    Trusted Proxies: %s", strings.Join(serverConfig.TrustedProxies, ", "))
+	} else {
+		logrus.Info("    Trusted Proxies: none (client IP is the connection address)")
+	}
 
 	logrus.Info("  --- Logging ---")
 	logrus.Infof("    Log Level: %s", logConfig.Level)
@@ -12,6 +12,7 @@ import (
 	"gpt-load/internal/utils"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -306,6 +307,16 @@ func (sm *SystemSettingsManager) ValidateSettings(settingsMap map[string]any) er
 						return fmt.Errorf("value for %s is required", key)
 					}
 				}
+				if trimmedRule == "cidr_list" {
+					if _, err := utils.ParseCIDRList(strVal); err != nil {
+						return fmt.Errorf("invalid value for %s: %v", key, err)
+					}
+				}
+				if strings.HasPrefix(trimmedRule, "oneof=") {
+					if !slices.Contains(strings.Fields(strings.TrimPrefix(trimmedRule, "oneof=")), strVal) {
+						return fmt.Errorf("invalid value for %s: must be one of %s", key, strings.TrimPrefix(trimmedRule, "oneof="))
+					}
+				}
 			}
 		default:
 			return fmt.Errorf("unsupported type for setting key validation: %s", key)
@@ -376,6 +387,16 @@ func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[stri
 						return fmt.Errorf("value for %s is required", key)
 					}
 				}
+				if trimmedRule == "cidr_list" {
+					if _, err := utils.ParseCIDRList(strVal); err != nil {
+						return fmt.Errorf("invalid value for %s: %v", key, err)
+					}
+				}
+				if strings.HasPrefix(trimmedRule, "oneof=") {
+					if !slices.Contains(strings.Fields(strings.TrimPrefix(trimmedRule, "oneof=")), strVal) {
+						return fmt.Errorf("invalid value for %s: must be one of %s", key, strings.TrimPrefix(trimmedRule, "oneof="))
+					}
+				}
 			}
 		case reflect.Bool:
 			_, ok := value.(bool)
@@ -411,6 +432,8 @@ func (sm *SystemSettingsManager) DisplaySystemConfig(settings types.SystemSettin
 	logrus.Infof("    Max Retries: %d", settings.MaxRetries)
 	logrus.Infof("    Blacklist Threshold: %d", settings.BlacklistThreshold)
 	logrus.Infof("    Key Validation Interval: %d minutes", settings.KeyValidationIntervalMinutes)
+	logrus.Infof("    Key Selection Strategy: %s", settings.KeyStrategy)
+	logrus.Infof("    Respect Upstream Rate Limits: %t (reserve %d%%)", settings.RespectUpstreamRateLimits, settings.RateLimitReservePercent)
 	logrus.Info("====================================")
 	logrus.Info("")
 }
@@ -79,6 +79,15 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(services.NewGroupManager); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewProxyKeyService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewGroupConcurrencyLimiter); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewProxyKeyLimiter); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(services.NewGroupService); err != nil {
 		return nil, err
 	}
@@ -38,6 +38,10 @@ var (
 	ErrNoActiveKeys       = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_ACTIVE_KEYS", Message: "No active API keys available for this group"}
 	ErrMaxRetriesExceeded = &APIError{HTTPStatus: http.StatusBadGateway, Code: "MAX_RETRIES_EXCEEDED", Message: "Request failed after maximum retries"}
 	ErrNoKeysAvailable    = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_KEYS_AVAILABLE", Message: "No API keys available to process the request"}
+	ErrServerBusy         = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "SERVER_BUSY", Message: "Too many concurrent requests, please try again later"}
+	ErrTooManyRequests    = &APIError{HTTPStatus: http.StatusTooManyRequests, Code: "TOO_MANY_REQUESTS", Message: "Too many requests, please try again later"}
+	ErrRequestTooLarge    = &APIError{HTTPStatus: http.StatusRequestEntityTooLarge, Code: "REQUEST_TOO_LARGE", Message: "Request body exceeds the maximum upload size"}
+	ErrKeyUnavailable     = &APIError{HTTPStatus: http.StatusGone, Code: "KEY_UNAVAILABLE", Message: "The API key bound to this resource is no longer available"}
 )
 
 // NewAPIError creates a new APIError with a custom message.
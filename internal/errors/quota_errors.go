@@ -0,0 +1,30 @@
+package errors
+
+import "strings"
+
+// quotaExhaustedSubstrings contains a list of substrings that indicate the key has run out of quota or balance.
+var quotaExhaustedSubstrings = []string{
+	"insufficient_quota",
+	"exceeded your current quota",
+	"credit balance is too low",
+	"insufficient balance",
+	"insufficient_balance",
+	"billing_hard_limit_reached",
+}
+
+// IsQuotaExhausted checks if the given error message indicates that the key has run out of quota or balance.
+func IsQuotaExhausted(errorMsg string) bool {
+	if errorMsg == "" {
+		return false
+	}
+
+	errorLower := strings.ToLower(errorMsg)
+
+	for _, pattern := range quotaExhaustedSubstrings {
+		if strings.Contains(errorLower, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
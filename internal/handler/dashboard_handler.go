@@ -7,6 +7,8 @@ import (
 	"gpt-load/internal/i18n"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,9 +18,11 @@ import (
 
 // Stats Get dashboard statistics
 func (s *Server) Stats(c *gin.Context) {
-	var activeKeys, invalidKeys int64
-	s.DB.Model(&models.APIKey{}).Where("status = ?", models.KeyStatusActive).Count(&activeKeys)
-	s.DB.Model(&models.APIKey{}).Where("status = ?", models.KeyStatusInvalid).Count(&invalidKeys)
+	// 拉黑的密钥单独计数，其余非活跃状态按状态分别返回
+	keyStats, err := services.CountKeysByStatus(s.DB)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to count keys by status")
+	}
 
 	now := time.Now()
 	rpmStats, err := s.getRPMStats(now)
@@ -88,13 +92,19 @@ func (s *Server) Stats(c *gin.Context) {
 		errorRateTrendIsGrowth = true
 	}
 
+	costStats, err := s.getCostStats(twentyFourHoursAgo, fortyEightHoursAgo, now)
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.cost_stats_failed")
+		return
+	}
+
 	// 获取安全警告信息
 	securityWarnings := s.getSecurityWarnings(c)
 
 	stats := models.DashboardStatsResponse{
 		KeyCount: models.StatCard{
-			Value:       float64(activeKeys),
-			SubValue:    invalidKeys,
+			Value:       float64(keyStats.ActiveKeys),
+			SubValue:    keyStats.InvalidKeys,
 			SubValueTip: i18n.Message(c, "dashboard.invalid_keys"),
 		},
 		RPM: rpmStats,
@@ -108,20 +118,38 @@ func (s *Server) Stats(c *gin.Context) {
 			Trend:         errorRateTrend,
 			TrendIsGrowth: errorRateTrendIsGrowth,
 		},
+		KeyStatusCounts:  keyStats.StatusCounts,
+		Cost:             costStats,
 		SecurityWarnings: securityWarnings,
 	}
 
 	response.Success(c, stats)
 }
 
+// Chart metrics
+const (
+	chartMetricRequests = "requests"
+	chartMetricTokens   = "tokens"
+	chartMetricCost     = "cost"
+	chartMetricLatency  = "latency"
+)
+
 // Chart Get dashboard chart data
 func (s *Server) Chart(c *gin.Context) {
 	groupID := c.Query("groupId")
+	model := c.Query("model")
+	metric := c.DefaultQuery("metric", chartMetricRequests)
 
 	now := time.Now()
 	endHour := now.Truncate(time.Hour)
 	startHour := endHour.Add(-23 * time.Hour)
 
+	// 按模型筛选或查看 Token、费用、耗时时使用分组模型小时统计表
+	if model != "" || metric != chartMetricRequests {
+		s.modelChart(c, groupID, model, metric, startHour)
+		return
+	}
+
 	var hourlyStats []models.GroupHourlyStat
 	query := s.DB.Table("group_hourly_stats").
 		Where("time >= ? AND time < ?", startHour, endHour.Add(time.Hour))
@@ -147,15 +175,15 @@ func (s *Server) Chart(c *gin.Context) {
 	}
 
 	var labels []string
-	var successData, failureData []int64
+	var successData, failureData []float64
 
 	for i := range 24 {
 		hour := startHour.Add(time.Duration(i) * time.Hour)
 		labels = append(labels, hour.Format(time.RFC3339))
 
 		if data, ok := statsByHour[hour]; ok {
-			successData = append(successData, data["success"])
-			failureData = append(failureData, data["failure"])
+			successData = append(successData, float64(data["success"]))
+			failureData = append(failureData, float64(data["failure"]))
 		} else {
 			successData = append(successData, 0)
 			failureData = append(failureData, 0)
@@ -181,6 +209,282 @@ func (s *Server) Chart(c *gin.Context) {
 	response.Success(c, chartData)
 }
 
+// CostSummary Get dashboard cost totals by group and model, with an hourly cost trend
+func (s *Server) CostSummary(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 || hours > maxCostSummaryHours {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_hours")
+		return
+	}
+
+	now := time.Now()
+	endHour := now.Truncate(time.Hour)
+	startHour := endHour.Add(-time.Duration(hours-1) * time.Hour)
+
+	byGroup, err := s.getCostBreakdown("group_id", startHour)
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.cost_stats_failed")
+		return
+	}
+	byModel, err := s.getCostBreakdown("model", startHour)
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.cost_stats_failed")
+		return
+	}
+
+	statsByHour, err := s.getModelHourlyStats("", "", startHour, endHour.Add(time.Hour))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.cost_stats_failed")
+		return
+	}
+
+	var totalCost float64
+	for _, item := range byGroup {
+		totalCost += item.Cost
+	}
+
+	labels := make([]string, 0, hours)
+	costData := make([]float64, 0, hours)
+	for i := range hours {
+		hour := startHour.Add(time.Duration(i) * time.Hour)
+		labels = append(labels, hour.Format(time.RFC3339))
+		if stat, ok := statsByHour[hour]; ok {
+			costData = append(costData, stat.Cost)
+		} else {
+			costData = append(costData, 0)
+		}
+	}
+
+	response.Success(c, models.CostSummaryResponse{
+		TotalCost: totalCost,
+		ByGroup:   byGroup,
+		ByModel:   byModel,
+		Trend: models.ChartData{
+			Labels: labels,
+			Datasets: []models.ChartDataset{
+				{
+					Label: i18n.Message(c, "dashboard.cost"),
+					Data:  costData,
+					Color: "rgba(240, 160, 32, 1)",
+				},
+			},
+		},
+	})
+}
+
+// ProxyKeyUsage Get dashboard requests, errors, tokens and cost per proxy key
+func (s *Server) ProxyKeyUsage(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 || hours > maxCostSummaryHours {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_hours")
+		return
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	var items []models.ProxyKeyUsageItem
+	err = s.DB.Model(&models.RequestLog{}).
+		Select("proxy_key_id, COUNT(*) as requests, COALESCE(SUM(CASE WHEN is_success THEN 0 ELSE 1 END), 0) as errors, COALESCE(SUM(prompt_tokens), 0) as prompt_tokens, COALESCE(SUM(completion_tokens), 0) as completion_tokens, COALESCE(SUM(cost), 0) as cost").
+		Where("timestamp >= ? AND request_type = ?", since, models.RequestTypeFinal).
+		Group("proxy_key_id").
+		Order("requests desc").
+		Scan(&items).Error
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.proxy_key_stats_failed")
+		return
+	}
+
+	// 填充代理密钥名称，未登记的旧版代理密钥归为一项
+	var keys []models.ProxyKey
+	if err := s.DB.Select("id, name").Find(&keys).Error; err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.proxy_key_stats_failed")
+		return
+	}
+	names := make(map[uint]string, len(keys))
+	for _, key := range keys {
+		names[key.ID] = key.Name
+	}
+	for i := range items {
+		if items[i].ProxyKeyID == 0 {
+			items[i].Name = i18n.Message(c, "dashboard.unregistered_proxy_key")
+		} else if name, ok := names[items[i].ProxyKeyID]; ok {
+			items[i].Name = name
+		} else {
+			items[i].Name = i18n.Message(c, "dashboard.deleted_proxy_key")
+		}
+	}
+
+	response.Success(c, items)
+}
+
+// maxCostSummaryHours 费用统计支持的最大时间范围
+const maxCostSummaryHours = 24 * 30
+
+// getCostBreakdown 按指定列汇总分组模型小时统计中的费用和 Token 用量，聚合分组不重复计数
+func (s *Server) getCostBreakdown(column string, since time.Time) ([]models.CostBreakdownItem, error) {
+	var items []models.CostBreakdownItem
+	err := s.DB.Model(&models.GroupModelHourlyStat{}).
+		Select(column+" as name, COALESCE(SUM(success_count + failure_count), 0) as requests, COALESCE(SUM(prompt_tokens), 0) as prompt_tokens, COALESCE(SUM(completion_tokens), 0) as completion_tokens, COALESCE(SUM(cost), 0) as cost").
+		Where("time >= ?", since).
+		Where("group_id NOT IN (?)",
+			s.DB.Table("groups").Select("id").Where("group_type = ?", "aggregate")).
+		Group(column).
+		Order("cost desc").
+		Scan(&items).Error
+	if err != nil || column != "group_id" {
+		return items, err
+	}
+
+	// 分组维度将 ID 替换为分组名
+	var groups []models.Group
+	if err := s.DB.Select("id, name").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(groups))
+	for _, group := range groups {
+		names[strconv.FormatUint(uint64(group.ID), 10)] = group.Name
+	}
+	for i := range items {
+		if name, ok := names[items[i].Name]; ok {
+			items[i].Name = name
+		}
+	}
+	return items, nil
+}
+
+type costStatResult struct {
+	CurrentCost  float64
+	PreviousCost float64
+}
+
+func (s *Server) getCostStats(currentStart, previousStart, now time.Time) (models.StatCard, error) {
+	var result costStatResult
+	err := s.DB.Model(&models.RequestLog{}).
+		Select("COALESCE(SUM(case when timestamp >= ? then cost else 0 end), 0) as current_cost, COALESCE(SUM(case when timestamp < ? then cost else 0 end), 0) as previous_cost", currentStart, currentStart).
+		Where("timestamp >= ? AND timestamp < ? AND cost > 0", previousStart, now).
+		Scan(&result).Error
+	if err != nil {
+		return models.StatCard{}, err
+	}
+
+	costTrend := 0.0
+	if result.PreviousCost > 0 {
+		costTrend = (result.CurrentCost - result.PreviousCost) / result.PreviousCost * 100
+	} else if result.CurrentCost > 0 {
+		costTrend = 100.0
+	}
+
+	return models.StatCard{
+		Value:         result.CurrentCost,
+		Trend:         costTrend,
+		TrendIsGrowth: costTrend >= 0,
+	}, nil
+}
+
+// modelChart plots a metric from group_model_hourly_stats over the 24 hours starting at startHour
+func (s *Server) modelChart(c *gin.Context, groupID, model, metric string, startHour time.Time) {
+	switch metric {
+	case chartMetricRequests, chartMetricTokens, chartMetricCost, chartMetricLatency:
+	default:
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_chart_metric")
+		return
+	}
+
+	statsByHour, err := s.getModelHourlyStats(groupID, model, startHour, startHour.Add(24*time.Hour))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.chart_data_failed")
+		return
+	}
+
+	var labels []string
+	series := make([][]float64, 2)
+	for i := range 24 {
+		hour := startHour.Add(time.Duration(i) * time.Hour)
+		labels = append(labels, hour.Format(time.RFC3339))
+
+		stat := statsByHour[hour]
+		if stat == nil {
+			stat = &models.GroupModelHourlyStat{}
+		}
+		switch metric {
+		case chartMetricRequests:
+			series[0] = append(series[0], float64(stat.SuccessCount))
+			series[1] = append(series[1], float64(stat.FailureCount))
+		case chartMetricTokens:
+			series[0] = append(series[0], float64(stat.PromptTokens))
+			series[1] = append(series[1], float64(stat.CompletionTokens))
+		case chartMetricCost:
+			series[0] = append(series[0], stat.Cost)
+		case chartMetricLatency:
+			avg := 0.0
+			if stat.LatencyCount > 0 {
+				avg = float64(stat.LatencySum) / float64(stat.LatencyCount)
+			}
+			series[0] = append(series[0], avg)
+		}
+	}
+
+	var datasets []models.ChartDataset
+	switch metric {
+	case chartMetricRequests:
+		datasets = []models.ChartDataset{
+			{Label: i18n.Message(c, "dashboard.success_requests"), Data: series[0], Color: "rgba(10, 200, 110, 1)"},
+			{Label: i18n.Message(c, "dashboard.failed_requests"), Data: series[1], Color: "rgba(255, 70, 70, 1)"},
+		}
+	case chartMetricTokens:
+		datasets = []models.ChartDataset{
+			{Label: i18n.Message(c, "dashboard.prompt_tokens"), Data: series[0], Color: "rgba(64, 128, 255, 1)"},
+			{Label: i18n.Message(c, "dashboard.completion_tokens"), Data: series[1], Color: "rgba(150, 90, 230, 1)"},
+		}
+	case chartMetricCost:
+		datasets = []models.ChartDataset{
+			{Label: i18n.Message(c, "dashboard.cost"), Data: series[0], Color: "rgba(240, 160, 32, 1)"},
+		}
+	case chartMetricLatency:
+		datasets = []models.ChartDataset{
+			{Label: i18n.Message(c, "dashboard.avg_latency"), Data: series[0], Color: "rgba(32, 180, 200, 1)"},
+		}
+	}
+
+	response.Success(c, models.ChartData{Labels: labels, Datasets: datasets})
+}
+
+// getModelHourlyStats 汇总分组模型小时统计，未指定分组时排除聚合分组以免重复计数
+func (s *Server) getModelHourlyStats(groupID, model string, startTime, endTime time.Time) (map[time.Time]*models.GroupModelHourlyStat, error) {
+	var hourlyStats []models.GroupModelHourlyStat
+	query := s.DB.Model(&models.GroupModelHourlyStat{}).
+		Where("time >= ? AND time < ?", startTime, endTime)
+	if groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else {
+		query = query.Where("group_id NOT IN (?)",
+			s.DB.Table("groups").Select("id").Where("group_type = ?", "aggregate"))
+	}
+	if model != "" {
+		query = query.Where("model = ?", model)
+	}
+	if err := query.Find(&hourlyStats).Error; err != nil {
+		return nil, err
+	}
+
+	statsByHour := make(map[time.Time]*models.GroupModelHourlyStat)
+	for _, stat := range hourlyStats {
+		hour := stat.Time.Local().Truncate(time.Hour)
+		total, ok := statsByHour[hour]
+		if !ok {
+			total = &models.GroupModelHourlyStat{Time: hour}
+			statsByHour[hour] = total
+		}
+		total.SuccessCount += stat.SuccessCount
+		total.FailureCount += stat.FailureCount
+		total.PromptTokens += stat.PromptTokens
+		total.CompletionTokens += stat.CompletionTokens
+		total.Cost += stat.Cost
+		total.LatencySum += stat.LatencySum
+		total.LatencyCount += stat.LatencyCount
+	}
+	return statsByHour, nil
+}
+
 type hourlyStatResult struct {
 	TotalRequests int64
 	TotalFailures int64
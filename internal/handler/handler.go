@@ -31,6 +31,8 @@ type Server struct {
 	KeyImportService           *services.KeyImportService
 	KeyDeleteService           *services.KeyDeleteService
 	LogService                 *services.LogService
+	ProxyKeyService            *services.ProxyKeyService
+	ProxyKeyLimiter            *services.ProxyKeyLimiter
 	CommonHandler              *CommonHandler
 	EncryptionSvc              encryption.Service
 }
@@ -50,6 +52,8 @@ type NewServerParams struct {
 	KeyImportService           *services.KeyImportService
 	KeyDeleteService           *services.KeyDeleteService
 	LogService                 *services.LogService
+	ProxyKeyService            *services.ProxyKeyService
+	ProxyKeyLimiter            *services.ProxyKeyLimiter
 	CommonHandler              *CommonHandler
 	EncryptionSvc              encryption.Service
 }
@@ -69,6 +73,8 @@ func NewServer(params NewServerParams) *Server {
 		KeyImportService:           params.KeyImportService,
 		KeyDeleteService:           params.KeyDeleteService,
 		LogService:                 params.LogService,
+		ProxyKeyService:            params.ProxyKeyService,
+		ProxyKeyLimiter:            params.ProxyKeyLimiter,
 		CommonHandler:              params.CommonHandler,
 		EncryptionSvc:              params.EncryptionSvc,
 	}
@@ -77,7 +77,7 @@ func (s *Server) GetIntegrationInfo(c *gin.Context) {
 
 	var result []IntegrationGroupInfo
 	for _, group := range groupsToCheck {
-		if hasProxyKeyPermission(group, key) {
+		if _, ok := s.ProxyKeyService.Authorize(key, group); ok {
 			channelType := getEffectiveChannelType(group)
 			path := buildPath(isGroupSpecific, group.Name, channelType, group.ValidationEndpoint)
 
@@ -117,13 +117,6 @@ func getEffectiveChannelType(group *models.Group) string {
 	return "custom"
 }
 
-// hasProxyKeyPermission checks if the key has permission to access the group
-func hasProxyKeyPermission(group *models.Group, key string) bool {
-	_, exists1 := group.ProxyKeysMap[key]
-	_, exists2 := group.EffectiveConfig.ProxyKeysMap[key]
-	return exists1 || exists2
-}
-
 // buildPath returns the appropriate path based on request type and channel type
 func buildPath(isGroupSpecific bool, groupName string, channelType string, validationEndpoint string) string {
 	if channelType == "custom" {
@@ -5,7 +5,9 @@ import (
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -64,6 +66,37 @@ type KeyTextRequest struct {
 	KeysText string `json:"keys_text" binding:"required"`
 }
 
+// KeyWeightRequest defines the payload for setting the weight and priority of keys.
+// Keys of the highest priority that have active keys take all requests, shared by weight.
+type KeyWeightRequest struct {
+	GroupID  uint   `json:"group_id" binding:"required"`
+	KeysText string `json:"keys_text" binding:"required"`
+	Weight   int    `json:"weight" binding:"required,min=1,max=1000"`
+	Priority int    `json:"priority" binding:"min=-100,max=100"`
+}
+
+// KeyStatusRequest defines the payload for setting the status of keys.
+// CooldownMinutes is required for the cooldown status, after which the keys return to active.
+type KeyStatusRequest struct {
+	GroupID         uint   `json:"group_id" binding:"required"`
+	KeysText        string `json:"keys_text" binding:"required"`
+	Status          string `json:"status" binding:"required,oneof=active disabled cooldown quota_exhausted expired"`
+	CooldownMinutes int    `json:"cooldown_minutes" binding:"required_if=Status cooldown,min=0,max=43200"`
+}
+
+// KeyMetadataRequest defines the payload for setting the metadata of keys. Omitted fields are left unchanged,
+// and clear_expiry removes the expiry date.
+type KeyMetadataRequest struct {
+	GroupID     uint       `json:"group_id" binding:"required"`
+	KeysText    string     `json:"keys_text" binding:"required"`
+	Name        *string    `json:"name" binding:"omitempty,max=255"`
+	Notes       *string    `json:"notes" binding:"omitempty,max=4000"`
+	Labels      *[]string  `json:"labels" binding:"omitempty,max=20,dive,max=64"`
+	Source      *string    `json:"source" binding:"omitempty,max=255"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	ClearExpiry bool       `json:"clear_expiry"`
+}
+
 // GroupIDRequest defines a generic payload for operations requiring only a group ID.
 type GroupIDRequest struct {
 	GroupID uint `json:"group_id" binding:"required"`
@@ -144,7 +177,7 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 	}
 
 	statusFilter := c.Query("status")
-	if statusFilter != "" && statusFilter != models.KeyStatusActive && statusFilter != models.KeyStatusInvalid {
+	if statusFilter != "" && !slices.Contains(models.KeyStatuses, statusFilter) {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
 		return
 	}
@@ -155,7 +188,7 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 		searchHash = s.EncryptionSvc.Hash(searchKeyword)
 	}
 
-	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchHash)
+	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchHash, strings.TrimSpace(c.Query("label")))
 
 	var keys []models.APIKey
 	paginatedResult, err := response.Paginate(c, query, &keys)
@@ -267,6 +300,77 @@ func (s *Server) RestoreMultipleKeys(c *gin.Context) {
 	response.Success(c, result)
 }
 
+// UpdateKeyWeights handles setting the weight and priority of keys from a text block within a specific group.
+func (s *Server) UpdateKeyWeights(c *gin.Context) {
+	var req KeyWeightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	s.updateKeysFromText(c, req.GroupID, req.KeysText, func() (*services.UpdateKeysResult, error) {
+		return s.KeyService.UpdateKeyWeights(req.GroupID, req.KeysText, req.Weight, req.Priority)
+	})
+}
+
+// UpdateKeyStatus handles setting the status of keys from a text block within a specific group.
+func (s *Server) UpdateKeyStatus(c *gin.Context) {
+	var req KeyStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	s.updateKeysFromText(c, req.GroupID, req.KeysText, func() (*services.UpdateKeysResult, error) {
+		return s.KeyService.UpdateKeyStatus(req.GroupID, req.KeysText, req.Status, req.CooldownMinutes)
+	})
+}
+
+// UpdateKeyMetadata handles setting the name, notes, labels, source and expiry date of keys from a text block.
+func (s *Server) UpdateKeyMetadata(c *gin.Context) {
+	var req KeyMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	s.updateKeysFromText(c, req.GroupID, req.KeysText, func() (*services.UpdateKeysResult, error) {
+		return s.KeyService.UpdateKeyMetadata(req.GroupID, req.KeysText, services.KeyMetadataUpdate{
+			Name:        req.Name,
+			Notes:       req.Notes,
+			Labels:      req.Labels,
+			Source:      req.Source,
+			ExpiresAt:   req.ExpiresAt,
+			ClearExpiry: req.ClearExpiry,
+		})
+	})
+}
+
+// updateKeysFromText checks the group and the keys text, runs the update and responds with its result.
+func (s *Server) updateKeysFromText(c *gin.Context, groupID uint, keysText string, update func() (*services.UpdateKeysResult, error)) {
+	if _, ok := s.findGroupByID(c, groupID); !ok {
+		return
+	}
+
+	if !validateKeysText(c, keysText) {
+		return
+	}
+
+	result, err := update()
+	if err != nil {
+		if strings.Contains(err.Error(), "batch size exceeds the limit") {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else if err.Error() == "no valid keys found in the input text" || err.Error() == "no metadata to update" {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
 // TestMultipleKeys handles a one-off validation test for multiple keys.
 func (s *Server) TestMultipleKeys(c *gin.Context) {
 	var req KeyTextRequest
@@ -319,7 +423,7 @@ func (s *Server) ValidateGroupKeys(c *gin.Context) {
 	}
 
 	// Validate status if provided
-	if req.Status != "" && req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid {
+	if req.Status != "" && !slices.Contains(models.KeyStatuses, req.Status) {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_value")
 		return
 	}
@@ -419,9 +523,7 @@ func (s *Server) ExportKeys(c *gin.Context) {
 		statusFilter = "all"
 	}
 
-	switch statusFilter {
-	case "all", models.KeyStatusActive, models.KeyStatusInvalid:
-	default:
+	if statusFilter != "all" && !slices.Contains(models.KeyStatuses, statusFilter) {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
 		return
 	}
@@ -435,7 +537,7 @@ func (s *Server) ExportKeys(c *gin.Context) {
 	c.Header("Content-Disposition", "attachment; filename="+filename)
 	c.Header("Content-Type", "text/plain; charset=utf-8")
 
-	if err := s.KeyService.StreamKeysToWriter(groupID, statusFilter, c.Writer); err != nil {
+	if err := s.KeyService.StreamKeysToWriter(groupID, statusFilter, strings.TrimSpace(c.Query("label")), c.Writer); err != nil {
 		log.Printf("Failed to stream keys: %v", err)
 	}
 }
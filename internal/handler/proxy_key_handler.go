@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProxyKeyRequest defines the payload for creating or updating a proxy key.
+// Omitted fields keep their current value on update.
+type ProxyKeyRequest struct {
+	Name        *string    `json:"name,omitempty"`
+	Key         *string    `json:"key,omitempty"`
+	Enabled     *bool      `json:"enabled,omitempty"`
+	AllGroups   *bool      `json:"all_groups,omitempty"`
+	GroupIDs    []uint     `json:"group_ids,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ClearExpiry bool       `json:"clear_expiry,omitempty"`
+
+	RPMLimit         *int    `json:"rpm_limit,omitempty"`
+	TPMLimit         *int    `json:"tpm_limit,omitempty"`
+	ConcurrencyLimit *int    `json:"concurrency_limit,omitempty"`
+	Priority         *string `json:"priority,omitempty"`
+
+	QuotaPeriod *string  `json:"quota_period,omitempty"`
+	TokenQuota  *int64   `json:"token_quota,omitempty"`
+	SpendQuota  *float64 `json:"spend_quota,omitempty"`
+
+	AllowedModels   *string `json:"allowed_models,omitempty"`
+	MaxOutputTokens *int    `json:"max_output_tokens,omitempty"`
+	DenyTools       *bool   `json:"deny_tools,omitempty"`
+	DenyVision      *bool   `json:"deny_vision,omitempty"`
+}
+
+// ProxyKeyResponse adds the usage in the current quota period to a proxy key.
+type ProxyKeyResponse struct {
+	models.ProxyKey
+	Quota *services.QuotaStatus `json:"quota"`
+}
+
+// ProxyKeyQuotaResponse is returned to a proxy key holder querying their own usage.
+type ProxyKeyQuotaResponse struct {
+	Name             string                `json:"name"`
+	KeyHint          string                `json:"key_hint"`
+	Enabled          bool                  `json:"enabled"`
+	ExpiresAt        *time.Time            `json:"expires_at"`
+	RPMLimit         int                   `json:"rpm_limit"`
+	TPMLimit         int                   `json:"tpm_limit"`
+	ConcurrencyLimit int                   `json:"concurrency_limit"`
+	Quota            *services.QuotaStatus `json:"quota"`
+}
+
+// ProxyKeyCreateResponse returns the secret of a new proxy key. It is only shown once.
+type ProxyKeyCreateResponse struct {
+	models.ProxyKey
+	Key string `json:"key"`
+}
+
+func (r *ProxyKeyRequest) toParams() services.ProxyKeyParams {
+	return services.ProxyKeyParams{
+		Name:        r.Name,
+		Key:         r.Key,
+		Enabled:     r.Enabled,
+		AllGroups:   r.AllGroups,
+		GroupIDs:    r.GroupIDs,
+		ExpiresAt:   r.ExpiresAt,
+		ClearExpiry: r.ClearExpiry,
+
+		RPMLimit:         r.RPMLimit,
+		TPMLimit:         r.TPMLimit,
+		ConcurrencyLimit: r.ConcurrencyLimit,
+		Priority:         r.Priority,
+
+		QuotaPeriod: r.QuotaPeriod,
+		TokenQuota:  r.TokenQuota,
+		SpendQuota:  r.SpendQuota,
+
+		AllowedModels:   r.AllowedModels,
+		MaxOutputTokens: r.MaxOutputTokens,
+		DenyTools:       r.DenyTools,
+		DenyVision:      r.DenyVision,
+	}
+}
+
+// ListProxyKeys handles listing all proxy keys.
+func (s *Server) ListProxyKeys(c *gin.Context) {
+	keys, err := s.ProxyKeyService.ListProxyKeys(c.Request.Context())
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	result := make([]ProxyKeyResponse, 0, len(keys))
+	for i := range keys {
+		quota, err := s.ProxyKeyService.GetQuotaStatus(&keys[i])
+		if s.handleGroupError(c, err) {
+			return
+		}
+		result = append(result, ProxyKeyResponse{ProxyKey: keys[i], Quota: quota})
+	}
+	response.Success(c, result)
+}
+
+// GetProxyKeyQuota lets a proxy key holder query the limits and usage of their own key.
+// The key is read from a Bearer token or the X-Api-Key header, never from the URL where it would be logged.
+// Lookups are rate limited per client address.
+func (s *Server) GetProxyKeyQuota(c *gin.Context) {
+	if retryAfter, ok := s.ProxyKeyLimiter.AllowQuotaLookup(c.ClientIP()); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		response.Error(c, app_errors.ErrTooManyRequests)
+		return
+	}
+
+	secret := ""
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		secret = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if secret == "" {
+		secret = c.GetHeader("X-Api-Key")
+	}
+
+	key, ok := s.ProxyKeyService.Lookup(secret)
+	if secret == "" || !ok {
+		response.Error(c, app_errors.ErrUnauthorized)
+		return
+	}
+
+	quota, err := s.ProxyKeyService.GetQuotaStatus(key)
+	if s.handleGroupError(c, err) {
+		return
+	}
+	response.Success(c, ProxyKeyQuotaResponse{
+		Name:             key.Name,
+		KeyHint:          key.KeyHint,
+		Enabled:          key.Enabled,
+		ExpiresAt:        key.ExpiresAt,
+		RPMLimit:         key.RPMLimit,
+		TPMLimit:         key.TPMLimit,
+		ConcurrencyLimit: key.ConcurrencyLimit,
+		Quota:            quota,
+	})
+}
+
+// CreateProxyKey handles the creation of a new proxy key.
+func (s *Server) CreateProxyKey(c *gin.Context) {
+	var req ProxyKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	key, secret, err := s.ProxyKeyService.CreateProxyKey(c.Request.Context(), req.toParams())
+	if s.handleGroupError(c, err) {
+		return
+	}
+	response.Success(c, ProxyKeyCreateResponse{ProxyKey: *key, Key: secret})
+}
+
+// UpdateProxyKey handles updating an existing proxy key.
+func (s *Server) UpdateProxyKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_proxy_key_id")
+		return
+	}
+
+	var req ProxyKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	key, err := s.ProxyKeyService.UpdateProxyKey(c.Request.Context(), uint(id), req.toParams())
+	if s.handleGroupError(c, err) {
+		return
+	}
+	response.Success(c, key)
+}
+
+// DeleteProxyKey handles deleting a proxy key.
+func (s *Server) DeleteProxyKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_proxy_key_id")
+		return
+	}
+
+	if s.handleGroupError(c, s.ProxyKeyService.DeleteProxyKey(c.Request.Context(), uint(id))) {
+		return
+	}
+	response.SuccessI18n(c, "success.proxy_key_deleted", nil)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // GetSettings handles the GET /api/settings request.
@@ -77,12 +78,21 @@ func (s *Server) UpdateSettings(c *gin.Context) {
 		}
 	}
 
+	previousProxyKeys := s.SettingsManager.GetSettings().ProxyKeys
+
 	// 更新配置
 	if err := s.SettingsManager.UpdateSettings(settingsMap); err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
 		return
 	}
 
+	// 新增的全局代理密钥同步导入代理密钥列表
+	if proxyKeys, ok := settingsMap["proxy_keys"].(string); ok {
+		if err := s.ProxyKeyService.ImportLegacyKeys(c.Request.Context(), previousProxyKeys, proxyKeys, 0, "global"); err != nil {
+			logrus.WithContext(c.Request.Context()).WithError(err).Error("failed to import global proxy keys")
+		}
+	}
+
 	time.Sleep(100 * time.Millisecond) // 等待异步更新配置
 
 	response.SuccessI18n(c, "settings.update_success", nil)
@@ -13,12 +13,14 @@ import (
 	"gorm.io/gorm"
 )
 
-// NewCronChecker is responsible for periodically validating invalid keys.
+// NewCronChecker is responsible for periodically validating invalid and quota exhausted keys,
+// restoring keys whose cooldown has ended and expiring keys past their expiry date.
 type CronChecker struct {
 	DB              *gorm.DB
 	SettingsManager *config.SystemSettingsManager
 	Validator       *KeyValidator
 	EncryptionSvc   encryption.Service
+	KeyProvider     *KeyProvider
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
 }
@@ -29,12 +31,14 @@ func NewCronChecker(
 	settingsManager *config.SystemSettingsManager,
 	validator *KeyValidator,
 	encryptionSvc encryption.Service,
+	keyProvider *KeyProvider,
 ) *CronChecker {
 	return &CronChecker{
 		DB:              db,
 		SettingsManager: settingsManager,
 		Validator:       validator,
 		EncryptionSvc:   encryptionSvc,
+		KeyProvider:     keyProvider,
 		stopChan:        make(chan struct{}),
 	}
 }
@@ -42,8 +46,9 @@ func NewCronChecker(
 // Start begins the cron job execution.
 func (s *CronChecker) Start() {
 	logrus.Debug("Starting CronChecker...")
-	s.wg.Add(1)
+	s.wg.Add(2)
 	go s.runLoop()
+	go s.runKeyStatusLoop()
 }
 
 // Stop stops the cron job, respecting the context for shutdown timeout.
@@ -84,6 +89,34 @@ func (s *CronChecker) runLoop() {
 	}
 }
 
+// runKeyStatusLoop restores keys whose cooldown has ended and expires keys past their expiry date every minute.
+func (s *CronChecker) runKeyStatusLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			restored, err := s.KeyProvider.RestoreCooledDownKeys()
+			if err != nil {
+				logrus.Errorf("CronChecker: Failed to restore cooled down keys: %v", err)
+			} else if restored > 0 {
+				logrus.Infof("CronChecker: Restored %d keys after their cooldown.", restored)
+			}
+			expired, err := s.KeyProvider.ExpireKeys()
+			if err != nil {
+				logrus.Errorf("CronChecker: Failed to expire keys: %v", err)
+			} else if expired > 0 {
+				logrus.Infof("CronChecker: Disabled %d keys past their expiry date.", expired)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
 // submitValidationJobs finds groups whose keys need validation and validates them concurrently.
 func (s *CronChecker) submitValidationJobs() {
 	var groups []models.Group
@@ -114,11 +147,12 @@ func (s *CronChecker) submitValidationJobs() {
 }
 
 // validateGroupKeys validates all invalid keys for a single group concurrently.
+// Quota exhausted keys are validated too, which serves as their balance check.
 func (s *CronChecker) validateGroupKeys(group *models.Group) {
 	groupProcessStart := time.Now()
 
 	var invalidKeys []models.APIKey
-	err := s.DB.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusInvalid).Find(&invalidKeys).Error
+	err := s.DB.Where("group_id = ? AND status IN ?", group.ID, []string{models.KeyStatusInvalid, models.KeyStatusQuotaExhausted}).Find(&invalidKeys).Error
 	if err != nil {
 		logrus.Errorf("CronChecker: Failed to get invalid keys for group %s: %v", group.Name, err)
 		return
@@ -0,0 +1,181 @@
+package keypool
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"gpt-load/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// keyInFlightNodesKey maps each node to the last time it reported the in-flight counts it holds.
+	keyInFlightNodesKey = "key_pool:in_flight_nodes"
+	// inFlightReconcileLockKey lets one node per interval take back the counts of nodes that went away.
+	inFlightReconcileLockKey = "key_pool:in_flight_nodes:reconcile"
+
+	// inFlightReportInterval is how often a node reports its in-flight counts.
+	inFlightReportInterval = 30 * time.Second
+	// inFlightNodeTimeout is how long a node may go without reporting before its counts are taken back.
+	inFlightNodeTimeout = 2 * time.Minute
+)
+
+// inFlightTracker keeps the in-flight counts this node added to the shared key_pool:in_flight hash.
+// Each node reports them under its own ID, so that the counts of a node that crashed or lost the store
+// are taken back instead of keeping its keys looking busy forever.
+type inFlightTracker struct {
+	store  store.Store
+	nodeID string
+
+	mu     sync.Mutex
+	counts map[uint]int64
+}
+
+func newInFlightTracker(s store.Store) *inFlightTracker {
+	return &inFlightTracker{
+		store:  s,
+		nodeID: uuid.NewString(),
+		counts: make(map[uint]int64),
+	}
+}
+
+// acquire counts a request this node started on the key.
+func (t *inFlightTracker) acquire(keyID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counts[keyID]++
+}
+
+// release counts a finished request and reports whether the node still held it, which is false
+// once the count has been given back on shutdown.
+func (t *inFlightTracker) release(keyID uint) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts[keyID] <= 0 {
+		return false
+	}
+	t.counts[keyID]--
+	if t.counts[keyID] == 0 {
+		delete(t.counts, keyID)
+	}
+	return true
+}
+
+func (t *inFlightTracker) snapshot() map[string]any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[string]any, len(t.counts))
+	for keyID, count := range t.counts {
+		counts[strconv.FormatUint(uint64(keyID), 10)] = count
+	}
+	return counts
+}
+
+func nodeInFlightKey(nodeID string) string {
+	return fmt.Sprintf("%s:%s", keyInFlightKey, nodeID)
+}
+
+// report publishes the counts this node holds and marks the node as alive.
+func (t *inFlightTracker) report() {
+	nodeKey := nodeInFlightKey(t.nodeID)
+	if err := t.store.Del(nodeKey); err != nil {
+		logrus.WithError(err).Warn("Failed to reset in-flight key report")
+		return
+	}
+	if counts := t.snapshot(); len(counts) > 0 {
+		if err := t.store.HSet(nodeKey, counts); err != nil {
+			logrus.WithError(err).Warn("Failed to report in-flight keys")
+			return
+		}
+	}
+	if err := t.store.HSet(keyInFlightNodesKey, map[string]any{t.nodeID: time.Now().UnixMilli()}); err != nil {
+		logrus.WithError(err).Warn("Failed to report in-flight node heartbeat")
+	}
+}
+
+// reconcile takes back the in-flight counts of nodes that stopped reporting. Only one node per interval does so.
+func (t *inFlightTracker) reconcile() {
+	acquired, err := t.store.SetNX(inFlightReconcileLockKey, []byte(t.nodeID), inFlightReportInterval-time.Second)
+	if err != nil || !acquired {
+		return
+	}
+
+	nodes, err := t.store.HGetAll(keyInFlightNodesKey)
+	if err != nil {
+		logrus.Warnf("Failed to load in-flight nodes: %v", err)
+		return
+	}
+	staleBefore := time.Now().Add(-inFlightNodeTimeout).UnixMilli()
+	for nodeID, lastSeen := range nodes {
+		if seen, _ := strconv.ParseInt(lastSeen, 10, 64); nodeID == t.nodeID || seen > staleBefore {
+			continue
+		}
+		logrus.WithField("node", nodeID).Info("Taking back the in-flight key counts of a node that stopped reporting")
+		t.giveBack(nodeID)
+	}
+}
+
+// giveBack subtracts the reported counts of a node from the shared hash and forgets the node.
+func (t *inFlightTracker) giveBack(nodeID string) {
+	nodeKey := nodeInFlightKey(nodeID)
+	counts, err := t.store.HGetAll(nodeKey)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"node": nodeID, "error": err}).Warn("Failed to load in-flight key report")
+		return
+	}
+	for field, value := range counts {
+		count, _ := strconv.ParseInt(value, 10, 64)
+		if count <= 0 {
+			continue
+		}
+		remaining, err := t.store.HIncrBy(keyInFlightKey, field, -count)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": field, "error": err}).Warn("Failed to take back in-flight key count")
+			continue
+		}
+		if remaining <= 0 {
+			if err := t.store.HDel(keyInFlightKey, field); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": field, "error": err}).Warn("Failed to clear in-flight key")
+			}
+		}
+	}
+	if err := t.store.Del(nodeKey); err != nil {
+		logrus.WithFields(logrus.Fields{"node": nodeID, "error": err}).Warn("Failed to delete in-flight key report")
+	}
+	if err := t.store.HDel(keyInFlightNodesKey, nodeID); err != nil {
+		logrus.WithFields(logrus.Fields{"node": nodeID, "error": err}).Warn("Failed to forget in-flight node")
+	}
+}
+
+// shutdown gives back the counts of requests still running on this node when it stops.
+func (t *inFlightTracker) shutdown() {
+	t.report()
+	t.mu.Lock()
+	t.counts = make(map[uint]int64)
+	t.mu.Unlock()
+	t.giveBack(t.nodeID)
+}
+
+// reportInFlightKeys keeps this node's in-flight counts reported and reconciles those of vanished nodes.
+func (p *KeyProvider) reportInFlightKeys() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(inFlightReportInterval)
+	defer ticker.Stop()
+
+	p.inFlight.report()
+	for {
+		select {
+		case <-ticker.C:
+			p.inFlight.report()
+			p.inFlight.reconcile()
+		case <-p.stopChan:
+			p.inFlight.shutdown()
+			return
+		}
+	}
+}
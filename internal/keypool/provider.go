@@ -11,6 +11,7 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -22,46 +23,112 @@ type KeyProvider struct {
 	store           store.Store
 	settingsManager *config.SystemSettingsManager
 	encryptionSvc   encryption.Service
+	strategies      map[string]KeyStrategy
+	inFlight        *inFlightTracker
+
+	waiters  keyWaiters
+	stopChan chan struct{}
+	wg       sync.WaitGroup
 }
 
 // NewProvider 创建一个新的 KeyProvider 实例。
 func NewProvider(db *gorm.DB, store store.Store, settingsManager *config.SystemSettingsManager, encryptionSvc encryption.Service) *KeyProvider {
+	inFlight := newInFlightTracker(store)
 	return &KeyProvider{
 		db:              db,
 		store:           store,
 		settingsManager: settingsManager,
 		encryptionSvc:   encryptionSvc,
+		strategies:      newKeyStrategies(store, inFlight),
+		inFlight:        inFlight,
 	}
 }
 
-// SelectKey 为指定的分组原子性地选择并轮换一个可用的 APIKey。
-func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+// SelectKey 按分组配置的选择策略原子性地选择一个可用的 APIKey。
+// 优先使用最高优先级的可用密钥。请求结束后需调用返回的 release，它绑定到做出选择的策略，可安全地多次调用。
+func (p *KeyProvider) SelectKey(group *models.Group) (*models.APIKey, func(), error) {
+	groupID := group.ID
+	strategy := p.strategyFor(group)
 
-	// 1. Atomically rotate the key ID from the list
-	keyIDStr, err := p.store.Rotate(activeKeysListKey)
+	// 1. Atomically select the key ID from the active list
+	keyIDStr, err := strategy.Select(groupID)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, app_errors.ErrNoActiveKeys
+			return nil, nil, app_errors.ErrNoActiveKeys
 		}
-		return nil, fmt.Errorf("failed to rotate key from store: %w", err)
+		return nil, nil, fmt.Errorf("failed to select key from store: %w", err)
 	}
 
 	keyID, err := strconv.ParseUint(keyIDStr, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
+		return nil, nil, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
 	}
+	release := sync.OnceFunc(func() { strategy.Release(uint(keyID)) })
 
 	// 2. Get key details from HASH
 	keyHashKey := fmt.Sprintf("key:%d", keyID)
 	keyDetails, err := p.store.HGetAll(keyHashKey)
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("failed to get key details for key ID %d: %w", keyID, err)
+	}
+
+	return p.keyFromDetails(uint(keyID), groupID, keyDetails), release, nil
+}
+
+// GetActiveKey 获取分组内指定 ID 的密钥，密钥已被删除或拉黑时返回 ErrKeyUnavailable。
+// 密钥按分组的选择策略计入使用，请求结束后需调用返回的 release。
+func (p *KeyProvider) GetActiveKey(group *models.Group, keyID uint) (*models.APIKey, func(), error) {
+	keyDetails, err := p.activeKeyDetails(group.ID, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.keyFromDetails(keyID, group.ID, keyDetails), p.acquireKey(group, keyID), nil
+}
+
+// GetSessionKey 与 GetActiveKey 相同，但因上游限流被暂停的密钥也视为不可用。
+func (p *KeyProvider) GetSessionKey(group *models.Group, keyID uint) (*models.APIKey, func(), error) {
+	keyDetails, err := p.activeKeyDetails(group.ID, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pausedUntil, _ := strconv.ParseInt(keyDetails["ratelimit_paused_until"], 10, 64); pausedUntil > time.Now().UnixMilli() {
+		return nil, nil, app_errors.NewAPIError(app_errors.ErrKeyUnavailable, "The API key is rate limited upstream")
+	}
+	return p.keyFromDetails(keyID, group.ID, keyDetails), p.acquireKey(group, keyID), nil
+}
+
+// acquireKey counts a request on a key picked without selection and returns its release.
+func (p *KeyProvider) acquireKey(group *models.Group, keyID uint) func() {
+	strategy := p.strategyFor(group)
+	strategy.Acquire(keyID)
+	return sync.OnceFunc(func() { strategy.Release(keyID) })
+}
+
+func (p *KeyProvider) activeKeyDetails(groupID, keyID uint) (map[string]string, error) {
+	keyDetails, err := p.store.HGetAll(fmt.Sprintf("key:%d", keyID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get key details for key ID %d: %w", keyID, err)
 	}
+	if len(keyDetails) == 0 || keyDetails["group_id"] != strconv.FormatUint(uint64(groupID), 10) {
+		return nil, app_errors.NewAPIError(app_errors.ErrKeyUnavailable, "The API key that owns this resource has been deleted")
+	}
+	if keyDetails["status"] != models.KeyStatusActive {
+		return nil, app_errors.NewAPIError(app_errors.ErrKeyUnavailable, "The API key that owns this resource has been blacklisted")
+	}
+	return keyDetails, nil
+}
 
-	// 3. Manually unmarshal the map into an APIKey struct
+// keyFromDetails builds an APIKey from its cached HASH fields.
+func (p *KeyProvider) keyFromDetails(keyID, groupID uint, keyDetails map[string]string) *models.APIKey {
+	// Manually unmarshal the map into an APIKey struct
 	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
 	createdAt, _ := strconv.ParseInt(keyDetails["created_at"], 10, 64)
+	weight, err := strconv.Atoi(keyDetails["weight"])
+	if err != nil || weight < 1 {
+		weight = 1
+	}
+	priority, _ := strconv.Atoi(keyDetails["priority"])
 
 	// Decrypt the key value for use by channels
 	encryptedKeyValue := keyDetails["key_string"]
@@ -76,15 +143,17 @@ func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
 	}
 
 	apiKey := &models.APIKey{
-		ID:           uint(keyID),
+		ID:           keyID,
 		KeyValue:     decryptedKeyValue,
 		Status:       keyDetails["status"],
 		FailureCount: failureCount,
+		Weight:       weight,
+		Priority:     priority,
 		GroupID:      groupID,
 		CreatedAt:    time.Unix(createdAt, 0),
 	}
 
-	return apiKey, nil
+	return apiKey
 }
 
 // UpdateStatus 异步地提交一个 Key 状态更新任务。
@@ -94,7 +163,7 @@ func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, i
 		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", group.ID)
 
 		if isSuccess {
-			if err := p.handleSuccess(apiKey.ID, keyHashKey, activeKeysListKey); err != nil {
+			if err := p.handleSuccess(apiKey.ID, group.ID, keyHashKey, activeKeysListKey); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key success")
 			}
 		} else {
@@ -104,7 +173,7 @@ func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, i
 					"error": errorMessage,
 				}).Debug("Uncounted error, skipping failure handling")
 			} else {
-				if err := p.handleFailure(apiKey, group, keyHashKey, activeKeysListKey); err != nil {
+				if err := p.handleFailure(apiKey, group, errorMessage, keyHashKey, activeKeysListKey); err != nil {
 					logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key failure")
 				}
 			}
@@ -139,20 +208,25 @@ func (p *KeyProvider) executeTransactionWithRetry(operation func(tx *gorm.DB) er
 	return err
 }
 
-func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey string) error {
+func (p *KeyProvider) handleSuccess(keyID, groupID uint, keyHashKey, activeKeysListKey string) error {
 	keyDetails, err := p.store.HGetAll(keyHashKey)
 	if err != nil {
 		return fmt.Errorf("failed to get key details from store: %w", err)
 	}
 
 	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
-	isActive := keyDetails["status"] == models.KeyStatusActive
+	status := keyDetails["status"]
+	isActive := status == models.KeyStatusActive
 
 	if failureCount == 0 && isActive {
 		return nil
 	}
+	// 只有被拉黑和额度耗尽的密钥会因验证成功而恢复，手动停用、冷却中和已过期的密钥保持不变
+	if !isActive && status != models.KeyStatusInvalid && status != models.KeyStatusQuotaExhausted {
+		return nil
+	}
 
-	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+	err = p.executeTransactionWithRetry(func(tx *gorm.DB) error {
 		var key models.APIKey
 		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&key, keyID).Error; err != nil {
 			return fmt.Errorf("failed to lock key %d for update: %w", keyID, err)
@@ -183,19 +257,24 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 
 		return nil
 	})
+	if err == nil && !isActive {
+		p.notifyKeyAvailable(groupID)
+	}
+	return err
 }
 
-func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, keyHashKey, activeKeysListKey string) error {
+func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, errorMessage, keyHashKey, activeKeysListKey string) error {
 	keyDetails, err := p.store.HGetAll(keyHashKey)
 	if err != nil {
 		return fmt.Errorf("failed to get key details from store: %w", err)
 	}
 
-	if keyDetails["status"] == models.KeyStatusInvalid {
+	if keyDetails["status"] != models.KeyStatusActive {
 		return nil
 	}
 
 	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
+	p.recordRecentFailure(apiKey.ID)
 
 	// 获取该分组的有效配置
 	blacklistThreshold := group.EffectiveConfig.BlacklistThreshold
@@ -209,9 +288,15 @@ func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group,
 		newFailureCount := failureCount + 1
 
 		updates := map[string]any{"failure_count": newFailureCount}
-		shouldBlacklist := blacklistThreshold > 0 && newFailureCount >= int64(blacklistThreshold)
-		if shouldBlacklist {
-			updates["status"] = models.KeyStatusInvalid
+		// 额度耗尽的密钥立即停用，不必等到拉黑阈值
+		var newStatus string
+		if app_errors.IsQuotaExhausted(errorMessage) {
+			newStatus = models.KeyStatusQuotaExhausted
+		} else if blacklistThreshold > 0 && newFailureCount >= int64(blacklistThreshold) {
+			newStatus = models.KeyStatusInvalid
+		}
+		if newStatus != "" {
+			updates["status"] = newStatus
 		}
 
 		if err := tx.Model(&key).Updates(updates).Error; err != nil {
@@ -222,13 +307,17 @@ func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group,
 			return fmt.Errorf("failed to increment failure count in store: %w", err)
 		}
 
-		if shouldBlacklist {
-			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "threshold": blacklistThreshold}).Warn("Key has reached blacklist threshold, disabling.")
+		if newStatus != "" {
+			if newStatus == models.KeyStatusQuotaExhausted {
+				logrus.WithField("keyID", apiKey.ID).Warn("Key has run out of quota, disabling until a balance check passes.")
+			} else {
+				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "threshold": blacklistThreshold}).Warn("Key has reached blacklist threshold, disabling.")
+			}
 			if err := p.store.LRem(activeKeysListKey, 0, apiKey.ID); err != nil {
 				return fmt.Errorf("failed to LRem key from active list: %w", err)
 			}
-			if err := p.store.HSet(keyHashKey, map[string]any{"status": models.KeyStatusInvalid}); err != nil {
-				return fmt.Errorf("failed to update key status to invalid in store: %w", err)
+			if err := p.store.HSet(keyHashKey, map[string]any{"status": newStatus}); err != nil {
+				return fmt.Errorf("failed to update key status to %s in store: %w", newStatus, err)
 			}
 		}
 
@@ -240,8 +329,22 @@ func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group,
 func (p *KeyProvider) LoadKeysFromDB() error {
 	logrus.Debug("First time startup, loading keys from DB...")
 
+	// 冷却期已过的密钥直接恢复，已过有效期的密钥标记为过期
+	now := time.Now()
+	if err := p.db.Model(&models.APIKey{}).
+		Where("status = ? AND cooldown_until <= ?", models.KeyStatusCooldown, now).
+		Updates(map[string]any{"status": models.KeyStatusActive, "failure_count": 0, "cooldown_until": nil}).Error; err != nil {
+		return fmt.Errorf("failed to restore cooled down keys: %w", err)
+	}
+	if err := p.db.Model(&models.APIKey{}).
+		Where("status IN ? AND expires_at <= ?", expirableStatuses, now).
+		Updates(map[string]any{"status": models.KeyStatusExpired, "cooldown_until": nil}).Error; err != nil {
+		return fmt.Errorf("failed to expire keys: %w", err)
+	}
+
 	// 1. 分批从数据库加载并使用 Pipeline 写入 Redis
 	allActiveKeyIDs := make(map[uint][]any)
+	allKeyWeights := make(map[uint]map[string]any)
 	batchSize := 1000
 	var batchKeys []*models.APIKey
 
@@ -268,6 +371,12 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 			if key.Status == models.KeyStatusActive {
 				allActiveKeyIDs[key.GroupID] = append(allActiveKeyIDs[key.GroupID], key.ID)
 			}
+			if _, ok := allKeyWeights[key.GroupID]; !ok {
+				allKeyWeights[key.GroupID] = make(map[string]any)
+			}
+			if !isDefaultKeyWeight(key) {
+				allKeyWeights[key.GroupID][fmt.Sprint(key.ID)] = store.FormatMemberWeight(key.Priority, key.Weight)
+			}
 		}
 
 		if pipeline != nil {
@@ -294,6 +403,22 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 		}
 	}
 
+	// 3. 重置进行中请求数等选择依据，限流暂停的密钥已重新加入列表
+	if err := p.store.Del(keyInFlightKey, keyRecentFailuresKey, keyRateLimitedKey); err != nil {
+		logrus.WithError(err).Error("Failed to reset key selection scores")
+	}
+
+	// 4. 更新所有分组的密钥权重
+	for groupID, weights := range allKeyWeights {
+		keyWeightsKey := fmt.Sprintf("group:%d:key_weights", groupID)
+		p.store.Del(keyWeightsKey, fmt.Sprintf("group:%d:key_weight_state", groupID))
+		if len(weights) > 0 {
+			if err := p.store.HSet(keyWeightsKey, weights); err != nil {
+				logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Error("Failed to HSet key weights for group")
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -317,6 +442,9 @@ func (p *KeyProvider) AddKeys(groupID uint, keys []models.APIKey) error {
 		return nil
 	})
 
+	if err == nil {
+		p.notifyKeyAvailable(groupID)
+	}
 	return err
 }
 
@@ -371,7 +499,7 @@ func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error
 	return deletedCount, err
 }
 
-// RestoreKeys 恢复组内所有无效的 Key。
+// RestoreKeys 恢复组内所有无效的 Key。已过的有效期会被清除。
 func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 	var invalidKeys []models.APIKey
 	var restoredCount int64
@@ -385,9 +513,11 @@ func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 			return nil
 		}
 
+		now := time.Now()
 		updates := map[string]any{
 			"status":        models.KeyStatusActive,
 			"failure_count": 0,
+			"expires_at":    pastExpiryCleared(now),
 		}
 		result := tx.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", groupID, models.KeyStatusInvalid).Updates(updates)
 		if result.Error != nil {
@@ -398,6 +528,7 @@ func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 		for _, key := range invalidKeys {
 			key.Status = models.KeyStatusActive
 			key.FailureCount = 0
+			clearPastExpiry(&key, now)
 			if err := p.addKeyToStore(&key); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key in store after DB update, rolling back transaction")
 				return err
@@ -406,10 +537,13 @@ func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 		return nil
 	})
 
+	if err == nil && restoredCount > 0 {
+		p.notifyKeyAvailable(groupID)
+	}
 	return restoredCount, err
 }
 
-// RestoreMultipleKeys 恢复指定的 Key。
+// RestoreMultipleKeys 恢复指定的 Key，包括手动停用、冷却中、额度耗尽和已过期的 Key。已过的有效期会被清除。
 func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int64, error) {
 	if len(keyValues) == 0 {
 		return 0, nil
@@ -431,7 +565,7 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 			return nil
 		}
 
-		if err := tx.Where("group_id = ? AND key_hash IN ? AND status = ?", groupID, keyHashes, models.KeyStatusInvalid).Find(&keysToRestore).Error; err != nil {
+		if err := tx.Where("group_id = ? AND key_hash IN ? AND status <> ?", groupID, keyHashes, models.KeyStatusActive).Find(&keysToRestore).Error; err != nil {
 			return err
 		}
 
@@ -441,9 +575,12 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 
 		keyIDsToRestore := pluckIDs(keysToRestore)
 
+		now := time.Now()
 		updates := map[string]any{
-			"status":        models.KeyStatusActive,
-			"failure_count": 0,
+			"status":         models.KeyStatusActive,
+			"failure_count":  0,
+			"cooldown_until": nil,
+			"expires_at":     pastExpiryCleared(now),
 		}
 		result := tx.Model(&models.APIKey{}).Where("id IN ?", keyIDsToRestore).Updates(updates)
 		if result.Error != nil {
@@ -454,6 +591,8 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 		for _, key := range keysToRestore {
 			key.Status = models.KeyStatusActive
 			key.FailureCount = 0
+			key.CooldownUntil = nil
+			clearPastExpiry(&key, now)
 			if err := p.addKeyToStore(&key); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key in store after DB update")
 				return err
@@ -463,9 +602,244 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 		return nil
 	})
 
+	if err == nil && restoredCount > 0 {
+		p.notifyKeyAvailable(groupID)
+	}
 	return restoredCount, err
 }
 
+// UpdateKeyWeights 批量设置 Key 的权重和优先级。
+func (p *KeyProvider) UpdateKeyWeights(groupID uint, keyValues []string, weight, priority int) (int64, error) {
+	if len(keyValues) == 0 {
+		return 0, nil
+	}
+
+	var keysToUpdate []models.APIKey
+	var updatedCount int64
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		var keyHashes []string
+		for _, keyValue := range keyValues {
+			keyHash := p.encryptionSvc.Hash(keyValue)
+			if keyHash != "" {
+				keyHashes = append(keyHashes, keyHash)
+			}
+		}
+
+		if len(keyHashes) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("group_id = ? AND key_hash IN ?", groupID, keyHashes).Find(&keysToUpdate).Error; err != nil {
+			return err
+		}
+
+		if len(keysToUpdate) == 0 {
+			return nil
+		}
+
+		updates := map[string]any{
+			"weight":   weight,
+			"priority": priority,
+		}
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keysToUpdate)).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		updatedCount = result.RowsAffected
+
+		for _, key := range keysToUpdate {
+			key.Weight = weight
+			key.Priority = priority
+			if err := p.store.HSet(fmt.Sprintf("key:%d", key.ID), updates); err != nil {
+				return fmt.Errorf("failed to update key details in store: %w", err)
+			}
+			if err := p.syncKeyWeight(&key); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to update key weight in store after DB update")
+				return err
+			}
+		}
+		return nil
+	})
+
+	return updatedCount, err
+}
+
+// UpdateKeyStatus 批量设置 Key 的状态。设为 active 时重置失败次数并清除已过的有效期，设为 cooldown 时需指定冷却截止时间。
+func (p *KeyProvider) UpdateKeyStatus(groupID uint, keyValues []string, status string, cooldownUntil *time.Time) (int64, error) {
+	if len(keyValues) == 0 {
+		return 0, nil
+	}
+
+	var keysToUpdate []models.APIKey
+	var updatedCount int64
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		var keyHashes []string
+		for _, keyValue := range keyValues {
+			keyHash := p.encryptionSvc.Hash(keyValue)
+			if keyHash != "" {
+				keyHashes = append(keyHashes, keyHash)
+			}
+		}
+
+		if len(keyHashes) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("group_id = ? AND key_hash IN ?", groupID, keyHashes).Find(&keysToUpdate).Error; err != nil {
+			return err
+		}
+
+		if len(keysToUpdate) == 0 {
+			return nil
+		}
+
+		if status != models.KeyStatusCooldown {
+			cooldownUntil = nil
+		}
+		updates := map[string]any{
+			"status":         status,
+			"cooldown_until": cooldownUntil,
+		}
+		now := time.Now()
+		if status == models.KeyStatusActive {
+			updates["failure_count"] = 0
+			updates["expires_at"] = pastExpiryCleared(now)
+		}
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keysToUpdate)).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		updatedCount = result.RowsAffected
+
+		for _, key := range keysToUpdate {
+			if status == models.KeyStatusActive {
+				key.Status = status
+				key.FailureCount = 0
+				key.CooldownUntil = nil
+				clearPastExpiry(&key, now)
+				if err := p.addKeyToStore(&key); err != nil {
+					logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to activate key in store after DB update")
+					return err
+				}
+				continue
+			}
+			if err := p.deactivateKeyInStore(key.ID, groupID, status); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to update key status in store after DB update")
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err == nil && updatedCount > 0 && status == models.KeyStatusActive {
+		p.notifyKeyAvailable(groupID)
+	}
+	return updatedCount, err
+}
+
+// RestoreCooledDownKeys 恢复所有冷却期已过的 Key。
+func (p *KeyProvider) RestoreCooledDownKeys() (int64, error) {
+	var keysToRestore []models.APIKey
+	var restoredCount int64
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ? AND cooldown_until <= ?", models.KeyStatusCooldown, time.Now()).Find(&keysToRestore).Error; err != nil {
+			return err
+		}
+
+		if len(keysToRestore) == 0 {
+			return nil
+		}
+
+		updates := map[string]any{
+			"status":         models.KeyStatusActive,
+			"failure_count":  0,
+			"cooldown_until": nil,
+		}
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keysToRestore)).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		restoredCount = result.RowsAffected
+
+		for _, key := range keysToRestore {
+			key.Status = models.KeyStatusActive
+			key.FailureCount = 0
+			key.CooldownUntil = nil
+			if err := p.addKeyToStore(&key); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore cooled down key in store after DB update")
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err == nil {
+		notified := make(map[uint]bool)
+		for _, key := range keysToRestore {
+			if !notified[key.GroupID] {
+				notified[key.GroupID] = true
+				p.notifyKeyAvailable(key.GroupID)
+			}
+		}
+	}
+	return restoredCount, err
+}
+
+// expirableStatuses 是到期后会被标记为过期的状态。停用、拉黑和额度耗尽的 Key 保持原状态，
+// 它们在重新启用时会清除已过的有效期。
+var expirableStatuses = []string{models.KeyStatusActive, models.KeyStatusCooldown}
+
+// pastExpiryCleared 是重新启用 Key 时 expires_at 的更新值，清除已过的有效期，使 Key 不会立即再次过期。
+func pastExpiryCleared(now time.Time) any {
+	return gorm.Expr("CASE WHEN expires_at <= ? THEN NULL ELSE expires_at END", now)
+}
+
+// clearPastExpiry 与 pastExpiryCleared 相同，作用于已加载的 Key。
+func clearPastExpiry(key *models.APIKey, now time.Time) {
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		key.ExpiresAt = nil
+	}
+}
+
+// ExpireKeys 将所有已过有效期的可用 Key 标记为过期并移出可用列表。
+func (p *KeyProvider) ExpireKeys() (int64, error) {
+	var keysToExpire []models.APIKey
+	var expiredCount int64
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status IN ? AND expires_at <= ?", expirableStatuses, time.Now()).Find(&keysToExpire).Error; err != nil {
+			return err
+		}
+
+		if len(keysToExpire) == 0 {
+			return nil
+		}
+
+		updates := map[string]any{
+			"status":         models.KeyStatusExpired,
+			"cooldown_until": nil,
+		}
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keysToExpire)).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		expiredCount = result.RowsAffected
+
+		for _, key := range keysToExpire {
+			if err := p.deactivateKeyInStore(key.ID, key.GroupID, models.KeyStatusExpired); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to expire key in store after DB update")
+				return err
+			}
+		}
+		return nil
+	})
+
+	return expiredCount, err
+}
+
 // RemoveInvalidKeys 移除组内所有无效的 Key。
 func (p *KeyProvider) RemoveInvalidKeys(groupID uint) (int64, error) {
 	return p.removeKeysByStatus(groupID, models.KeyStatusInvalid)
@@ -527,8 +901,8 @@ func (p *KeyProvider) RemoveKeysFromStore(groupID uint, keyIDs []uint) error {
 
 	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
 
-	// 第一步：直接删除整个 active_keys 列表
-	if err := p.store.Delete(activeKeysListKey); err != nil {
+	// 第一步：直接删除整个 active_keys 列表及权重信息
+	if err := p.store.Del(activeKeysListKey, fmt.Sprintf("group:%d:key_weights", groupID), fmt.Sprintf("group:%d:key_weight_state", groupID)); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"groupID": groupID,
 			"error":   err,
@@ -547,6 +921,8 @@ func (p *KeyProvider) RemoveKeysFromStore(groupID uint, keyIDs []uint) error {
 		}
 	}
 
+	p.clearKeyScores(keyIDs...)
+
 	logrus.WithFields(logrus.Fields{
 		"groupID":  groupID,
 		"keyCount": len(keyIDs),
@@ -564,7 +940,12 @@ func (p *KeyProvider) addKeyToStore(key *models.APIKey) error {
 		return fmt.Errorf("failed to HSet key details for key %d: %w", key.ID, err)
 	}
 
-	// 2. If active, add to the active LIST
+	// 2. Record a non-default weight or priority for selection
+	if err := p.syncKeyWeight(key); err != nil {
+		return err
+	}
+
+	// 3. If active, add to the active LIST
 	if key.Status == models.KeyStatusActive {
 		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", key.GroupID)
 		if err := p.store.LRem(activeKeysListKey, 0, key.ID); err != nil {
@@ -577,12 +958,28 @@ func (p *KeyProvider) addKeyToStore(key *models.APIKey) error {
 	return nil
 }
 
+// deactivateKeyInStore is a helper to take a key out of the active list and record its new status.
+func (p *KeyProvider) deactivateKeyInStore(keyID, groupID uint, status string) error {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
+		return fmt.Errorf("failed to LRem key from active list: %w", err)
+	}
+	if err := p.store.HSet(fmt.Sprintf("key:%d", keyID), map[string]any{"status": status}); err != nil {
+		return fmt.Errorf("failed to update key status to %s in store: %w", status, err)
+	}
+	return nil
+}
+
 // removeKeyFromStore is a helper to remove a single key from the cache.
 func (p *KeyProvider) removeKeyFromStore(keyID, groupID uint) error {
 	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
 	if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
 		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to LRem key from active list")
 	}
+	if err := p.store.HDel(fmt.Sprintf("group:%d:key_weights", groupID), fmt.Sprint(keyID)); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to remove key weight")
+	}
+	p.clearKeyScores(keyID)
 
 	keyHashKey := fmt.Sprintf("key:%d", keyID)
 	if err := p.store.Delete(keyHashKey); err != nil {
@@ -598,11 +995,34 @@ func (p *KeyProvider) apiKeyToMap(key *models.APIKey) map[string]any {
 		"key_string":    key.KeyValue,
 		"status":        key.Status,
 		"failure_count": key.FailureCount,
+		"weight":        max(key.Weight, 1),
+		"priority":      key.Priority,
 		"group_id":      key.GroupID,
 		"created_at":    key.CreatedAt.Unix(),
 	}
 }
 
+// syncKeyWeight records the weight and priority of a key in the group's weights hash.
+// Keys with the default weight and priority are left out, so groups without any take the plain rotation path.
+func (p *KeyProvider) syncKeyWeight(key *models.APIKey) error {
+	keyWeightsKey := fmt.Sprintf("group:%d:key_weights", key.GroupID)
+	if isDefaultKeyWeight(key) {
+		if err := p.store.HDel(keyWeightsKey, fmt.Sprint(key.ID)); err != nil {
+			return fmt.Errorf("failed to remove weight of key %d: %w", key.ID, err)
+		}
+		return nil
+	}
+	if err := p.store.HSet(keyWeightsKey, map[string]any{fmt.Sprint(key.ID): store.FormatMemberWeight(key.Priority, key.Weight)}); err != nil {
+		return fmt.Errorf("failed to set weight of key %d: %w", key.ID, err)
+	}
+	return nil
+}
+
+// isDefaultKeyWeight reports whether the key has weight 1 and priority 0. A weight below 1 counts as 1.
+func isDefaultKeyWeight(key *models.APIKey) bool {
+	return key.Weight <= 1 && key.Priority == 0
+}
+
 // pluckIDs extracts IDs from a slice of APIKey.
 func pluckIDs(keys []models.APIKey) []uint {
 	ids := make([]uint, len(keys))
@@ -0,0 +1,222 @@
+package keypool
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// keyRateLimitedKey holds the keys taken out of rotation until their upstream rate limit resets,
+	// as key ID to "groupID:resetUnixMilli".
+	keyRateLimitedKey = "key_pool:rate_limited"
+
+	// maxRateLimitPause bounds how long a key is taken out of rotation, in case of odd reset values.
+	maxRateLimitPause = time.Hour
+	// rateLimitRestoreInterval is how often paused keys are checked for their reset time.
+	rateLimitRestoreInterval = time.Second
+)
+
+// rateLimitHeaders describes one upstream rate limit: the remaining budget, the total limit and the reset time.
+type rateLimitHeaders struct {
+	name      string
+	remaining string
+	limit     string
+	reset     string
+}
+
+// OpenAI style headers, Anthropic headers and the generic form used by other OpenAI compatible providers.
+var rateLimitHeaderSets = []rateLimitHeaders{
+	{"requests", "X-Ratelimit-Remaining-Requests", "X-Ratelimit-Limit-Requests", "X-Ratelimit-Reset-Requests"},
+	{"tokens", "X-Ratelimit-Remaining-Tokens", "X-Ratelimit-Limit-Tokens", "X-Ratelimit-Reset-Tokens"},
+	{"requests", "Anthropic-Ratelimit-Requests-Remaining", "Anthropic-Ratelimit-Requests-Limit", "Anthropic-Ratelimit-Requests-Reset"},
+	{"tokens", "Anthropic-Ratelimit-Tokens-Remaining", "Anthropic-Ratelimit-Tokens-Limit", "Anthropic-Ratelimit-Tokens-Reset"},
+	{"input_tokens", "Anthropic-Ratelimit-Input-Tokens-Remaining", "Anthropic-Ratelimit-Input-Tokens-Limit", "Anthropic-Ratelimit-Input-Tokens-Reset"},
+	{"output_tokens", "Anthropic-Ratelimit-Output-Tokens-Remaining", "Anthropic-Ratelimit-Output-Tokens-Limit", "Anthropic-Ratelimit-Output-Tokens-Reset"},
+	{"requests", "X-Ratelimit-Remaining", "X-Ratelimit-Limit", "X-Ratelimit-Reset"},
+}
+
+// ObserveRateLimits records the rate limit budget an upstream response reports for the key. When the remaining
+// budget falls within the group's reserve, or the response is a 429 that says when to retry, the key is taken
+// out of rotation until the reset time. It reports whether the key is rate limited until a known time.
+func (p *KeyProvider) ObserveRateLimits(apiKey *models.APIKey, group *models.Group, statusCode int, header http.Header) bool {
+	cfg := group.EffectiveConfig
+	if !cfg.RespectUpstreamRateLimits {
+		return false
+	}
+
+	now := time.Now()
+	var resetAt time.Time
+	snapshot := make(map[string]any)
+	for _, set := range rateLimitHeaderSets {
+		remaining, err := strconv.ParseInt(strings.TrimSpace(header.Get(set.remaining)), 10, 64)
+		if err != nil {
+			continue
+		}
+		snapshot["ratelimit_remaining_"+set.name] = remaining
+		reset, hasReset := parseRateLimitReset(header.Get(set.reset), now)
+		if hasReset {
+			snapshot["ratelimit_reset_"+set.name] = reset.Unix()
+		}
+
+		exhausted := remaining <= 0
+		if limit, err := strconv.ParseInt(strings.TrimSpace(header.Get(set.limit)), 10, 64); err == nil && limit > 0 {
+			exhausted = remaining*100 <= limit*int64(cfg.RateLimitReservePercent)
+		}
+		if exhausted && hasReset && reset.After(resetAt) {
+			resetAt = reset
+		}
+	}
+
+	if statusCode == http.StatusTooManyRequests && resetAt.IsZero() {
+		if retryAt, ok := parseRateLimitReset(header.Get("Retry-After"), now); ok {
+			resetAt = retryAt
+		}
+	}
+
+	if len(snapshot) > 0 {
+		if err := p.store.HSet(fmt.Sprintf("key:%d", apiKey.ID), snapshot); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Warn("Failed to record key rate limits")
+		}
+	}
+
+	if !resetAt.After(now) {
+		return false
+	}
+	if limit := now.Add(maxRateLimitPause); resetAt.After(limit) {
+		resetAt = limit
+	}
+	p.pauseKey(apiKey.ID, group.ID, resetAt)
+	return true
+}
+
+// pauseKey takes an active key out of rotation until the given time. The last key of a group is kept,
+// so that requests are still served and the upstream decides.
+func (p *KeyProvider) pauseKey(keyID, groupID uint, until time.Time) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	removed, err := p.store.LRemUnlessLast(activeKeysListKey, keyID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to remove rate limited key from active list")
+		return
+	}
+	if !removed {
+		return
+	}
+
+	value := fmt.Sprintf("%d:%d", groupID, until.UnixMilli())
+	if err := p.store.HSet(keyRateLimitedKey, map[string]any{strconv.FormatUint(uint64(keyID), 10): value}); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to pause rate limited key")
+		// Nothing would restore the key, so put it straight back
+		if err := p.store.LPush(activeKeysListKey, keyID); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Error("Failed to put rate limited key back into active list")
+		}
+		return
+	}
+	// Lets session affinity skip the key too
+	if err := p.store.HSet(fmt.Sprintf("key:%d", keyID), map[string]any{"ratelimit_paused_until": until.UnixMilli()}); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to mark rate limited key")
+	}
+	logrus.WithFields(logrus.Fields{"keyID": keyID, "until": until.Format(time.RFC3339)}).Debug("Key paused until its upstream rate limit resets")
+}
+
+// restoreRateLimitedKeys puts paused keys back into rotation once their rate limit has reset.
+func (p *KeyProvider) restoreRateLimitedKeys() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(rateLimitRestoreInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			paused, err := p.store.HGetAll(keyRateLimitedKey)
+			if err != nil {
+				logrus.Warnf("Failed to load rate limited keys: %v", err)
+				continue
+			}
+			now := time.Now().UnixMilli()
+			for field, value := range paused {
+				groupIDStr, untilStr, _ := strings.Cut(value, ":")
+				until, _ := strconv.ParseInt(untilStr, 10, 64)
+				if until > now {
+					continue
+				}
+				keyID, err := strconv.ParseUint(field, 10, 64)
+				if err != nil {
+					continue
+				}
+				groupID, err := strconv.ParseUint(groupIDStr, 10, 64)
+				if err != nil {
+					continue
+				}
+				p.resumeKey(uint(keyID), uint(groupID), value)
+			}
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// resumeKey puts a paused key back into rotation. Only one node resumes a given pause.
+func (p *KeyProvider) resumeKey(keyID, groupID uint, pause string) {
+	claimed, err := p.store.SetNX(fmt.Sprintf("%s:%d:%s", keyRateLimitedKey, keyID, pause), []byte("1"), time.Minute)
+	if err != nil || !claimed {
+		return
+	}
+	if err := p.store.HDel(keyRateLimitedKey, strconv.FormatUint(uint64(keyID), 10)); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to clear rate limited key")
+	}
+	keyHashKey := fmt.Sprintf("key:%d", keyID)
+	if err := p.store.HDel(keyHashKey, "ratelimit_paused_until"); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to unmark rate limited key")
+	}
+
+	// The key may have been deleted or blacklisted meanwhile
+	keyDetails, err := p.store.HGetAll(keyHashKey)
+	if err != nil || keyDetails["status"] != models.KeyStatusActive || keyDetails["group_id"] != strconv.FormatUint(uint64(groupID), 10) {
+		return
+	}
+
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to LRem key before resuming it")
+		return
+	}
+	if err := p.store.LPush(activeKeysListKey, keyID); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to resume rate limited key")
+		return
+	}
+	p.notifyKeyAvailable(groupID)
+}
+
+// parseRateLimitReset parses a reset or Retry-After value: a duration such as "6m0s" or "20ms", a number of
+// seconds, a Unix timestamp, an RFC 3339 time or an HTTP date.
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), true
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		// Values this large are Unix timestamps rather than delays
+		if seconds > 1e9 {
+			return time.Unix(int64(seconds), 0), true
+		}
+		return now.Add(time.Duration(seconds * float64(time.Second))), true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,233 @@
+package keypool
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Key selection strategies, set per group by the key_strategy setting.
+const (
+	StrategyRoundRobin        = "round_robin"
+	StrategyRandom            = "random"
+	StrategyLeastRecentlyUsed = "least_recently_used"
+	StrategyLeastInFlight     = "least_in_flight"
+	StrategyFewestFailures    = "fewest_failures"
+)
+
+// Hashes of per-key selection scores, shared by all groups and keyed by key ID.
+const (
+	keyLastUsedKey       = "key_pool:last_used"
+	keyInFlightKey       = "key_pool:in_flight"
+	keyRecentFailuresKey = "key_pool:recent_failures"
+	failureDecayLockKey  = "key_pool:recent_failures:decay"
+)
+
+// failureDecayInterval is how often the recent failure counts are halved.
+const failureDecayInterval = 5 * time.Minute
+
+// KeyStrategy picks the next key of a group among its active keys.
+// Every selection must be a single atomic store operation, so that nodes sharing the store agree.
+// Keys of the highest priority that has active keys are always preferred.
+type KeyStrategy interface {
+	// Select returns the ID of the selected key, or store.ErrNotFound when the group has no active key.
+	Select(groupID uint) (string, error)
+	// Acquire counts a request on a key that was picked without Select, such as a pinned session key,
+	// as if the strategy had selected it.
+	Acquire(keyID uint)
+	// Release is called once a request that used a key selected by this strategy has finished.
+	// The caller must release through the strategy that made the selection.
+	Release(keyID uint)
+}
+
+// newKeyStrategies creates the built-in strategies by name.
+func newKeyStrategies(s store.Store, inFlight *inFlightTracker) map[string]KeyStrategy {
+	return map[string]KeyStrategy{
+		StrategyRoundRobin:        &roundRobinStrategy{store: s},
+		StrategyRandom:            &randomStrategy{store: s},
+		StrategyLeastRecentlyUsed: &leastRecentlyUsedStrategy{store: s},
+		StrategyLeastInFlight:     &leastInFlightStrategy{store: s, inFlight: inFlight},
+		StrategyFewestFailures:    &fewestFailuresStrategy{store: s},
+	}
+}
+
+// strategyFor returns the strategy configured for the group, falling back to round-robin.
+func (p *KeyProvider) strategyFor(group *models.Group) KeyStrategy {
+	if strategy, ok := p.strategies[group.EffectiveConfig.KeyStrategy]; ok {
+		return strategy
+	}
+	return p.strategies[StrategyRoundRobin]
+}
+
+// roundRobinStrategy rotates through the keys, in proportion to their weights.
+type roundRobinStrategy struct {
+	store store.Store
+}
+
+func (s *roundRobinStrategy) Select(groupID uint) (string, error) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	keyWeightsKey := fmt.Sprintf("group:%d:key_weights", groupID)
+	return s.store.SelectWeighted(activeKeysListKey, keyWeightsKey, fmt.Sprintf("group:%d:key_weight_state", groupID))
+}
+
+func (s *roundRobinStrategy) Acquire(uint) {}
+
+func (s *roundRobinStrategy) Release(uint) {}
+
+// randomStrategy picks a random key, with a probability proportional to its weight.
+type randomStrategy struct {
+	store store.Store
+}
+
+func (s *randomStrategy) Select(groupID uint) (string, error) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	return s.store.SelectRandom(activeKeysListKey, fmt.Sprintf("group:%d:key_weights", groupID))
+}
+
+func (s *randomStrategy) Acquire(uint) {}
+
+func (s *randomStrategy) Release(uint) {}
+
+// leastRecentlyUsedStrategy picks the key that was selected longest ago.
+type leastRecentlyUsedStrategy struct {
+	store store.Store
+}
+
+func (s *leastRecentlyUsedStrategy) Select(groupID uint) (string, error) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	keyWeightsKey := fmt.Sprintf("group:%d:key_weights", groupID)
+	return s.store.SelectLowest(activeKeysListKey, keyWeightsKey, keyLastUsedKey, time.Now().UnixNano(), false)
+}
+
+func (s *leastRecentlyUsedStrategy) Acquire(keyID uint) {
+	field := strconv.FormatUint(uint64(keyID), 10)
+	if err := s.store.HSet(keyLastUsedKey, map[string]any{field: time.Now().UnixNano()}); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to record key use")
+	}
+}
+
+func (s *leastRecentlyUsedStrategy) Release(uint) {}
+
+// leastInFlightStrategy picks the key with the fewest requests in progress, which keeps long streams
+// from piling up on the same key.
+type leastInFlightStrategy struct {
+	store    store.Store
+	inFlight *inFlightTracker
+}
+
+func (s *leastInFlightStrategy) Select(groupID uint) (string, error) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	keyWeightsKey := fmt.Sprintf("group:%d:key_weights", groupID)
+	keyIDStr, err := s.store.SelectLowest(activeKeysListKey, keyWeightsKey, keyInFlightKey, 1, true)
+	if err != nil {
+		return "", err
+	}
+	if keyID, err := strconv.ParseUint(keyIDStr, 10, 64); err == nil {
+		s.inFlight.acquire(uint(keyID))
+	}
+	return keyIDStr, nil
+}
+
+func (s *leastInFlightStrategy) Acquire(keyID uint) {
+	if _, err := s.store.HIncrBy(keyInFlightKey, strconv.FormatUint(uint64(keyID), 10), 1); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to acquire in-flight key")
+		return
+	}
+	s.inFlight.acquire(keyID)
+}
+
+func (s *leastInFlightStrategy) Release(keyID uint) {
+	// Counts already given back when this node stopped must not be subtracted twice
+	if !s.inFlight.release(keyID) {
+		return
+	}
+	field := strconv.FormatUint(uint64(keyID), 10)
+	count, err := s.store.HIncrBy(keyInFlightKey, field, -1)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to release in-flight key")
+		return
+	}
+	if count <= 0 {
+		// The count may have been reset while the request was running
+		if err := s.store.HDel(keyInFlightKey, field); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to clear in-flight key")
+		}
+	}
+}
+
+// fewestFailuresStrategy picks the key with the fewest recent failures.
+type fewestFailuresStrategy struct {
+	store store.Store
+}
+
+func (s *fewestFailuresStrategy) Select(groupID uint) (string, error) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	keyWeightsKey := fmt.Sprintf("group:%d:key_weights", groupID)
+	return s.store.SelectLowest(activeKeysListKey, keyWeightsKey, keyRecentFailuresKey, 0, true)
+}
+
+func (s *fewestFailuresStrategy) Acquire(uint) {}
+
+func (s *fewestFailuresStrategy) Release(uint) {}
+
+// recordRecentFailure counts a failed request of the key for the fewest-failures strategy.
+func (p *KeyProvider) recordRecentFailure(keyID uint) {
+	if _, err := p.store.HIncrBy(keyRecentFailuresKey, strconv.FormatUint(uint64(keyID), 10), 1); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to record recent key failure")
+	}
+}
+
+// clearKeyScores removes the selection scores and rate limit pauses of deleted keys.
+func (p *KeyProvider) clearKeyScores(keyIDs ...uint) {
+	fields := make([]string, len(keyIDs))
+	for i, keyID := range keyIDs {
+		fields[i] = strconv.FormatUint(uint64(keyID), 10)
+	}
+	for _, scoresKey := range []string{keyLastUsedKey, keyInFlightKey, keyRecentFailuresKey, keyRateLimitedKey} {
+		if err := p.store.HDel(scoresKey, fields...); err != nil {
+			logrus.WithFields(logrus.Fields{"scores": scoresKey, "error": err}).Warn("Failed to clear key selection scores")
+		}
+	}
+}
+
+// decayRecentFailures halves the recent failure counts every interval, so that old failures fade out.
+// Only one node per interval does so.
+func (p *KeyProvider) decayRecentFailures() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(failureDecayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			acquired, err := p.store.SetNX(failureDecayLockKey, []byte("1"), failureDecayInterval-time.Second)
+			if err != nil || !acquired {
+				continue
+			}
+			failures, err := p.store.HGetAll(keyRecentFailuresKey)
+			if err != nil {
+				logrus.Warnf("Failed to load recent key failures: %v", err)
+				continue
+			}
+			for field, value := range failures {
+				count, _ := strconv.ParseInt(value, 10, 64)
+				if count/2 > 0 {
+					_, err = p.store.HIncrBy(keyRecentFailuresKey, field, -(count - count/2))
+				} else {
+					err = p.store.HDel(keyRecentFailuresKey, field)
+				}
+				if err != nil {
+					logrus.Warnf("Failed to decay recent key failures: %v", err)
+				}
+			}
+		case <-p.stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// KeyAvailableChannel carries the ID of a group whose active key list gained a key.
+const KeyAvailableChannel = "key_pool:available"
+
+// keyWaiters tracks the requests waiting for a key, by group.
+type keyWaiters struct {
+	mu      sync.Mutex
+	byGroup map[uint]map[chan struct{}]struct{}
+}
+
+func (w *keyWaiters) add(groupID uint) chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.byGroup == nil {
+		w.byGroup = make(map[uint]map[chan struct{}]struct{})
+	}
+	if w.byGroup[groupID] == nil {
+		w.byGroup[groupID] = make(map[chan struct{}]struct{})
+	}
+	ch := make(chan struct{}, 1)
+	w.byGroup[groupID][ch] = struct{}{}
+	return ch
+}
+
+func (w *keyWaiters) remove(groupID uint, ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.byGroup[groupID], ch)
+	if len(w.byGroup[groupID]) == 0 {
+		delete(w.byGroup, groupID)
+	}
+}
+
+func (w *keyWaiters) wake(groupID uint) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.byGroup[groupID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start subscribes to key availability notifications so waiting requests on this node can be woken,
+// starts decaying the recent failure counts of the keys, resumes rate limited keys once their limit resets
+// and reports the in-flight counts of this node.
+func (p *KeyProvider) Start() {
+	p.stopChan = make(chan struct{})
+	p.wg.Add(4)
+	go p.listenKeyAvailable()
+	go p.decayRecentFailures()
+	go p.restoreRateLimitedKeys()
+	go p.reportInFlightKeys()
+}
+
+// Stop stops the key availability listener, the failure decay, the rate limit restore and the in-flight
+// report, giving back the in-flight counts of requests still running on this node.
+func (p *KeyProvider) Stop(ctx context.Context) {
+	if p.stopChan == nil {
+		return
+	}
+	close(p.stopChan)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("Key pool background tasks stopped.")
+	case <-ctx.Done():
+		logrus.Warn("Key pool background tasks stop timed out.")
+	}
+}
+
+func (p *KeyProvider) listenKeyAvailable() {
+	defer p.wg.Done()
+
+	for {
+		subscription, err := p.store.Subscribe(KeyAvailableChannel)
+		if err != nil {
+			logrus.Errorf("Failed to subscribe to key availability, retrying in 5s: %v", err)
+			select {
+			case <-time.After(5 * time.Second):
+				continue
+			case <-p.stopChan:
+				return
+			}
+		}
+
+	subscriberLoop:
+		for {
+			select {
+			case msg, ok := <-subscription.Channel():
+				if !ok {
+					logrus.Warn("Key availability subscription closed, attempting to re-subscribe...")
+					break subscriberLoop
+				}
+				if groupID, err := strconv.ParseUint(string(msg.Payload), 10, 64); err == nil {
+					p.waiters.wake(uint(groupID))
+				}
+			case <-p.stopChan:
+				if err := subscription.Close(); err != nil {
+					logrus.Errorf("Failed to close key availability subscription: %v", err)
+				}
+				return
+			}
+		}
+
+		if err := subscription.Close(); err != nil {
+			logrus.Errorf("Failed to close key availability subscription before retrying: %v", err)
+		}
+
+		select {
+		case <-time.After(2 * time.Second):
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// notifyKeyAvailable tells waiting requests on every node that the group has a usable key again.
+func (p *KeyProvider) notifyKeyAvailable(groupID uint) {
+	if err := p.store.Publish(KeyAvailableChannel, []byte(strconv.FormatUint(uint64(groupID), 10))); err != nil {
+		logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Warn("Failed to publish key availability")
+	}
+}
+
+// WaitForKey selects a key like SelectKey, but when the group has no active key it waits up to maxWait
+// for one to be added, restored or recovered. It returns early with the context error if ctx is done.
+func (p *KeyProvider) WaitForKey(ctx context.Context, group *models.Group, maxWait time.Duration) (*models.APIKey, func(), error) {
+	groupID := group.ID
+	apiKey, release, err := p.SelectKey(group)
+	if maxWait <= 0 || !errors.Is(err, app_errors.ErrNoActiveKeys) {
+		return apiKey, release, err
+	}
+
+	wake := p.waiters.add(groupID)
+	defer p.waiters.remove(groupID, wake)
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	// Check again now that a notification can no longer be missed
+	for {
+		apiKey, release, err = p.SelectKey(group)
+		if !errors.Is(err, app_errors.ErrNoActiveKeys) {
+			return apiKey, release, err
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			return nil, nil, err
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
@@ -2,15 +2,25 @@
 package middleware
 
 import (
+	"bytes"
 	"crypto/subtle"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"gpt-load/internal/config"
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
 	"gpt-load/internal/response"
 	"gpt-load/internal/services"
 	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -140,10 +150,10 @@ func Auth(authConfig types.AuthConfig) gin.HandlerFunc {
 }
 
 // ProxyAuth
-func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
+func ProxyAuth(gm *services.GroupManager, pks *services.ProxyKeyService, settingsManager *config.SystemSettingsManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check key
-		key := extractAuthKey(c)
+		key := proxyKeyFromContext(c)
 		if key == "" {
 			response.Error(c, app_errors.ErrUnauthorized)
 			c.Abort()
@@ -157,11 +167,23 @@ func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
 			return
 		}
 
-		// Check both key collections to prevent timing attacks
-		_, existsInEffective := group.EffectiveConfig.ProxyKeysMap[key]
-		_, existsInGroup := group.ProxyKeysMap[key]
+		if !clientIPAllowed(c.ClientIP(), group, settingsManager.GetSettings()) {
+			logrus.WithFields(logrus.Fields{
+				"group":     group.Name,
+				"client_ip": c.ClientIP(),
+			}).Debug("Proxy request rejected by IP access list")
+			response.ChannelError(c, group.ChannelType, http.StatusForbidden, "ip_not_allowed", "Requests from this IP address are not allowed.")
+			return
+		}
 
-		if existsInEffective || existsInGroup {
+		if record, ok := pks.Authorize(key, group); ok {
+			if record != nil {
+				if quotaErr := pks.CheckQuota(record); quotaErr != nil {
+					abortWithQuotaExceeded(c, group, quotaErr)
+					return
+				}
+				c.Set(services.ProxyKeyContextKey, record)
+			}
 			c.Next()
 			return
 		}
@@ -171,6 +193,270 @@ func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
 	}
 }
 
+// GroupConcurrency bounds the in-flight requests of the target group, queueing requests over the limit
+// by the priority class of their proxy key. It must run after ProxyAuth and before ProxyKeyLimits.
+func GroupConcurrency(gm *services.GroupManager, limiter *services.GroupConcurrencyLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := gm.GetGroupByName(c.Param("group_name"))
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to retrieve proxy group"))
+			c.Abort()
+			return
+		}
+
+		cfg := group.EffectiveConfig
+		priority := services.PriorityNormal
+		if record, ok := c.Get(services.ProxyKeyContextKey); ok {
+			priority = record.(*models.ProxyKey).Priority
+		}
+
+		release, admitErr := limiter.Acquire(c.Request.Context(), group.ID, cfg.GroupMaxConcurrency, cfg.GroupQueueSize,
+			time.Duration(cfg.GroupQueueTimeout)*time.Second, priority)
+		if admitErr != nil {
+			status, code := http.StatusServiceUnavailable, "server_overloaded"
+			if admitErr.QueueFull {
+				status, code = http.StatusTooManyRequests, "rate_limit_exceeded"
+			}
+			c.Header("Retry-After", "1")
+			logrus.WithFields(logrus.Fields{
+				"group":      group.Name,
+				"priority":   priority,
+				"queue_full": admitErr.QueueFull,
+			}).Debug("Request not admitted by group concurrency limit")
+			response.ChannelError(c, group.ChannelType, status, code, admitErr.Error())
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
+
+// ProxyKeyLimits enforces the request, token and concurrency limits of the proxy key. It runs once the
+// group has admitted the request, so that time spent in the group queue does not hold a slot of the key.
+func ProxyKeyLimits(gm *services.GroupManager, limiter *services.ProxyKeyLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(services.ProxyKeyContextKey)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		group, err := gm.GetGroupByName(c.Param("group_name"))
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to retrieve proxy group"))
+			c.Abort()
+			return
+		}
+
+		release, limitErr := limiter.Acquire(value.(*models.ProxyKey))
+		if limitErr != nil {
+			abortWithRateLimit(c, group, limitErr)
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
+
+// abortWithRateLimit rejects a request over its proxy key limits with a channel-native 429.
+func abortWithRateLimit(c *gin.Context, group *models.Group, limitErr *services.RateLimitError) {
+	retryAfter := int(limitErr.RetryAfter.Seconds())
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.Header("X-RateLimit-Limit-"+limitErr.Limit, strconv.Itoa(limitErr.Max))
+	c.Header("X-RateLimit-Remaining-"+limitErr.Limit, "0")
+	c.Header("X-RateLimit-Reset-"+limitErr.Limit, fmt.Sprintf("%ds", retryAfter))
+
+	logrus.WithFields(logrus.Fields{
+		"group": group.Name,
+		"limit": limitErr.Limit,
+	}).Debug("Proxy key rate limit reached")
+	response.ChannelError(c, group.ChannelType, http.StatusTooManyRequests, "rate_limit_exceeded", limitErr.Error())
+}
+
+// clientIPAllowed checks the client address against the IP access lists. The global deny list always
+// applies, while a group allow list replaces the global one. A list that fails to parse rejects every address.
+func clientIPAllowed(clientIP string, group *models.Group, global types.SystemSettings) bool {
+	denyLists := []string{global.IPDenyList, group.EffectiveConfig.IPDenyList}
+	allowList := group.EffectiveConfig.IPAllowList
+	if allowList == "" && denyLists[0] == "" && denyLists[1] == "" {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, list := range denyLists {
+		nets, err := utils.ParseCIDRList(list)
+		if err != nil {
+			logrus.WithError(err).Warn("Invalid IP deny list, rejecting request")
+			return false
+		}
+		if utils.IPInNets(ip, nets) {
+			return false
+		}
+	}
+
+	if allowList == "" {
+		return true
+	}
+	nets, err := utils.ParseCIDRList(allowList)
+	if err != nil {
+		logrus.WithError(err).Warn("Invalid IP allow list, rejecting request")
+		return false
+	}
+	return utils.IPInNets(ip, nets)
+}
+
+// abortWithQuotaExceeded rejects a request of a proxy key that has used up its quota with a channel-native 429.
+func abortWithQuotaExceeded(c *gin.Context, group *models.Group, quotaErr *services.QuotaExceededError) {
+	if quotaErr.ResetsAt != nil {
+		retryAfter := int(math.Ceil(time.Until(*quotaErr.ResetsAt).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"group": group.Name,
+		"quota": quotaErr.Quota,
+	}).Debug("Proxy key quota exhausted")
+	response.ChannelError(c, group.ChannelType, http.StatusTooManyRequests, "insufficient_quota", quotaErr.Error())
+}
+
+// GatewayRouter resolves the target group of a unified gateway request from the requested model.
+// The resolved group is exposed as the group_name route parameter so the regular proxy chain can follow.
+// GET /v1/models is answered with the models of the routes the proxy key may access.
+func GatewayRouter(gm *services.GroupManager, pks *services.ProxyKeyService, settingsManager *config.SystemSettingsManager,
+	modelLister interface {
+		HandleGatewayModelList(*gin.Context, []utils.ModelRoute)
+	}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := proxyKeyFromContext(c)
+		routes := utils.ParseModelRoutes(settingsManager.GetSettings().ModelRoutes)
+
+		// Check the key before reading the body, so that unknown keys learn nothing about the routes
+		var record *models.ProxyKey
+		accessible := make([]bool, len(routes))
+		authorized := false
+		if key != "" {
+			for i, route := range routes {
+				group, err := gm.GetGroupByName(route.Group)
+				if err != nil {
+					continue
+				}
+				if rec, ok := pks.Authorize(key, group); ok {
+					accessible[i] = true
+					authorized = true
+					if rec != nil {
+						record = rec
+					}
+				}
+			}
+		}
+		if !authorized {
+			response.Error(c, app_errors.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		if c.Request.Method == http.MethodGet && strings.TrimSuffix(c.Request.URL.Path, "/") == "/v1/models" {
+			var listed []utils.ModelRoute
+			for i, route := range routes {
+				if !accessible[i] {
+					continue
+				}
+				if group, err := gm.GetGroupByName(route.Group); err == nil && clientIPAllowed(c.ClientIP(), group, settingsManager.GetSettings()) {
+					listed = append(listed, route)
+				}
+			}
+			if record != nil {
+				c.Set(services.ProxyKeyContextKey, record)
+			}
+			modelLister.HandleGatewayModelList(c, listed)
+			c.Abort()
+			return
+		}
+
+		model, err := extractGatewayModel(c)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+			c.Abort()
+			return
+		}
+		if model == "" {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "A model is required to route gateway requests"))
+			c.Abort()
+			return
+		}
+
+		matched := false
+		for i, route := range routes {
+			if !utils.MatchModelPattern(route.Pattern, model) {
+				continue
+			}
+			matched = true
+
+			group, err := gm.GetGroupByName(route.Group)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{"model": model, "group": route.Group}).Warn("Gateway route points to an unknown group")
+				continue
+			}
+
+			if !accessible[i] {
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{"model": model, "group": group.Name}).Debug("Gateway request routed")
+			c.Params = append(c.Params, gin.Param{Key: "group_name", Value: group.Name})
+			c.Next()
+			return
+		}
+
+		if matched {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrForbidden, fmt.Sprintf("Proxy key has no access to any group serving model '%s'", model)))
+		} else {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrResourceNotFound, fmt.Sprintf("No gateway route for model '%s'", model)))
+		}
+		c.Abort()
+	}
+}
+
+// extractGatewayModel finds the requested model in the body, query string or Gemini-style path,
+// restoring the body so later handlers can read it again.
+func extractGatewayModel(c *gin.Context) (string, error) {
+	// Only JSON bodies are inspected; uploads must not be buffered here and can name the model via ?model=.
+	contentType := c.Request.Header.Get("Content-Type")
+	if c.Request.Body != nil && (contentType == "" || strings.Contains(contentType, "json")) {
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+		var payload struct {
+			Model string `json:"model"`
+		}
+		if err := json.Unmarshal(bodyBytes, &payload); err == nil && payload.Model != "" {
+			return strings.TrimPrefix(payload.Model, "models/"), nil
+		}
+	}
+
+	if model := c.Query("model"); model != "" {
+		return model, nil
+	}
+
+	parts := strings.Split(c.Request.URL.Path, "/")
+	for i, part := range parts {
+		if part == "models" && i+1 < len(parts) {
+			return strings.Split(parts[i+1], ":")[0], nil
+		}
+	}
+	return "", nil
+}
+
 // ProxyRouteDispatcher dispatches special routes before proxy authentication
 func ProxyRouteDispatcher(serverHandler interface{ GetIntegrationInfo(*gin.Context) }) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -204,7 +490,8 @@ func RateLimiter(config types.PerformanceConfig) gin.HandlerFunc {
 			defer func() { <-semaphore }()
 			c.Next()
 		default:
-			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Too many concurrent requests"))
+			c.Header("Retry-After", "1")
+			response.Error(c, app_errors.ErrServerBusy)
 			c.Abort()
 		}
 	}
@@ -243,6 +530,19 @@ func isMonitoringEndpoint(path string) bool {
 	return false
 }
 
+// proxyKeyContextKey caches the extracted proxy key, since extracting it strips the key query parameter.
+const proxyKeyContextKey = "proxy_key"
+
+// proxyKeyFromContext returns the proxy key of the request, extracting it on first use.
+func proxyKeyFromContext(c *gin.Context) string {
+	if key := c.GetString(proxyKeyContextKey); key != "" {
+		return key
+	}
+	key := extractAuthKey(c)
+	c.Set(proxyKeyContextKey, key)
+	return key
+}
+
 // extractAuthKey extracts a auth key.
 func extractAuthKey(c *gin.Context) string {
 	// Query key
@@ -272,6 +572,14 @@ func extractAuthKey(c *gin.Context) string {
 		return key
 	}
 
+	// Sec-WebSocket-Protocol, used by browser realtime clients that cannot set headers
+	for _, protocol := range strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",") {
+		protocol = strings.TrimSpace(protocol)
+		if key, ok := strings.CutPrefix(protocol, utils.WebSocketProtocolKeyPrefix); ok && key != "" {
+			return key
+		}
+	}
+
 	return ""
 }
 
@@ -9,10 +9,24 @@ import (
 
 // Key状态
 const (
-	KeyStatusActive  = "active"
-	KeyStatusInvalid = "invalid"
+	KeyStatusActive         = "active"
+	KeyStatusInvalid        = "invalid"         // 连续失败被拉黑，可被定时验证或手动恢复
+	KeyStatusDisabled       = "disabled"        // 手动停用，不会被自动恢复
+	KeyStatusCooldown       = "cooldown"        // 冷却中，到达 CooldownUntil 后自动恢复
+	KeyStatusQuotaExhausted = "quota_exhausted" // 额度耗尽，仅在余额检查（定时验证）通过后恢复
+	KeyStatusExpired        = "expired"         // 已过期，不会被自动恢复
 )
 
+// KeyStatuses lists all key statuses.
+var KeyStatuses = []string{
+	KeyStatusActive,
+	KeyStatusInvalid,
+	KeyStatusDisabled,
+	KeyStatusCooldown,
+	KeyStatusQuotaExhausted,
+	KeyStatusExpired,
+}
+
 // SystemSetting 对应 system_settings 表
 type SystemSetting struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -32,11 +46,20 @@ type GroupConfig struct {
 	MaxIdleConnsPerHost          *int    `json:"max_idle_conns_per_host,omitempty"`
 	ResponseHeaderTimeout        *int    `json:"response_header_timeout,omitempty"`
 	ProxyURL                     *string `json:"proxy_url,omitempty"`
+	MaxUploadSizeMB              *int    `json:"max_upload_size_mb,omitempty"`
 	MaxRetries                   *int    `json:"max_retries,omitempty"`
 	BlacklistThreshold           *int    `json:"blacklist_threshold,omitempty"`
 	KeyValidationIntervalMinutes *int    `json:"key_validation_interval_minutes,omitempty"`
 	KeyValidationConcurrency     *int    `json:"key_validation_concurrency,omitempty"`
 	KeyValidationTimeoutSeconds  *int    `json:"key_validation_timeout_seconds,omitempty"`
+	ResourceAffinityHours        *int    `json:"resource_affinity_hours,omitempty"`
+	KeyWaitTimeout               *int    `json:"key_wait_timeout,omitempty"`
+	KeyStrategy                  *string `json:"key_strategy,omitempty"`
+	RespectUpstreamRateLimits    *bool   `json:"respect_upstream_rate_limits,omitempty"`
+	RateLimitReservePercent      *int    `json:"rate_limit_reserve_percent,omitempty"`
+	SessionAffinityMinutes       *int    `json:"session_affinity_minutes,omitempty"`
+	SessionAffinityHeader        *string `json:"session_affinity_header,omitempty"`
+	SessionAffinityMessages      *int    `json:"session_affinity_messages,omitempty"`
 	EnableRequestBodyLogging     *bool   `json:"enable_request_body_logging,omitempty"`
 	MultimodalOnly               *bool   `json:"multimodal_only,omitempty"`
 	RemoveParams                 *string `json:"remove_params,omitempty"`
@@ -52,6 +75,18 @@ type GroupConfig struct {
 	ForceStreaming               *bool   `json:"force_streaming,omitempty"`
 	SystemPromptAppendText       *string `json:"system_prompt_append_text,omitempty"`
 	SystemPromptAppendMode       *string `json:"system_prompt_append_mode,omitempty"`
+	ModelAliases                 *string `json:"model_aliases,omitempty"`
+	ModelAllowList               *string `json:"model_allow_list,omitempty"`
+	StreamUsageInjection         *bool   `json:"stream_usage_injection,omitempty"`
+	MaxInputTokens               *string `json:"max_input_tokens,omitempty"`
+	TruncationTokenBudget        *int    `json:"truncation_token_budget,omitempty"`
+	TruncationKeepTurns          *int    `json:"truncation_keep_turns,omitempty"`
+	GroupMaxConcurrency          *int    `json:"group_max_concurrency,omitempty"`
+	GroupQueueSize               *int    `json:"group_queue_size,omitempty"`
+	GroupQueueTimeout            *int    `json:"group_queue_timeout,omitempty"`
+	PriceMultiplierPercent       *int    `json:"price_multiplier_percent,omitempty"`
+	IPAllowList                  *string `json:"ip_allow_list,omitempty"`
+	IPDenyList                   *string `json:"ip_deny_list,omitempty"`
 }
 
 // HeaderRule defines a single rule for header manipulation.
@@ -76,11 +111,12 @@ type GroupSubGroup struct {
 
 // SubGroupInfo 用于API响应的子分组信息
 type SubGroupInfo struct {
-	Group       Group `json:"group"`
-	Weight      int   `json:"weight"`
-	TotalKeys   int64 `json:"total_keys"`
-	ActiveKeys  int64 `json:"active_keys"`
-	InvalidKeys int64 `json:"invalid_keys"`
+	Group           Group            `json:"group"`
+	Weight          int              `json:"weight"`
+	TotalKeys       int64            `json:"total_keys"`
+	ActiveKeys      int64            `json:"active_keys"`
+	InvalidKeys     int64            `json:"invalid_keys"`
+	KeyStatusCounts map[string]int64 `json:"key_status_counts"` // 除 active 和 invalid 外各状态的密钥数量
 }
 
 // ParentAggregateGroupInfo 用于API响应的父聚合分组信息
@@ -122,16 +158,67 @@ type Group struct {
 
 // APIKey 对应 api_keys 表
 type APIKey struct {
-	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
-	KeyValue     string     `gorm:"type:text;not null" json:"key_value"`
-	KeyHash      string     `gorm:"type:varchar(128);index" json:"key_hash"`
-	GroupID      uint       `gorm:"not null;index" json:"group_id"`
-	Status       string     `gorm:"type:varchar(50);not null;default:'active'" json:"status"`
-	RequestCount int64      `gorm:"not null;default:0" json:"request_count"`
-	FailureCount int64      `gorm:"not null;default:0" json:"failure_count"`
-	LastUsedAt   *time.Time `json:"last_used_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID            uint                        `gorm:"primaryKey;autoIncrement" json:"id"`
+	KeyValue      string                      `gorm:"type:text;not null" json:"key_value"`
+	KeyHash       string                      `gorm:"type:varchar(128);index" json:"key_hash"`
+	GroupID       uint                        `gorm:"not null;index" json:"group_id"`
+	Status        string                      `gorm:"type:varchar(50);not null;default:'active'" json:"status"`
+	RequestCount  int64                       `gorm:"not null;default:0" json:"request_count"`
+	FailureCount  int64                       `gorm:"not null;default:0" json:"failure_count"`
+	Weight        int                         `gorm:"not null;default:1" json:"weight"`
+	Priority      int                         `gorm:"not null;default:0" json:"priority"`
+	CooldownUntil *time.Time                  `json:"cooldown_until"`
+	Name          string                      `gorm:"type:varchar(255);not null;default:''" json:"name"`
+	Notes         string                      `gorm:"type:text" json:"notes"`
+	Labels        datatypes.JSONSlice[string] `gorm:"type:json" json:"labels"`
+	Source        string                      `gorm:"type:varchar(255);not null;default:''" json:"source"` // 密钥来源，如供应商或提供人
+	ExpiresAt     *time.Time                  `gorm:"index" json:"expires_at"`
+	LastUsedAt    *time.Time                  `json:"last_used_at"`
+	CreatedAt     time.Time                   `json:"created_at"`
+	UpdatedAt     time.Time                   `json:"updated_at"`
+}
+
+// ProxyKey 对应 proxy_keys 表，客户端访问代理使用的密钥
+type ProxyKey struct {
+	ID               uint                      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name             string                    `gorm:"type:varchar(255);not null" json:"name"`
+	KeyHash          string                    `gorm:"type:varchar(128);not null;uniqueIndex" json:"-"`
+	KeyHint          string                    `gorm:"type:varchar(64)" json:"key_hint"`
+	Enabled          bool                      `gorm:"not null" json:"enabled"`
+	AllGroups        bool                      `gorm:"not null;default:false" json:"all_groups"`
+	GroupIDs         datatypes.JSONSlice[uint] `gorm:"type:json" json:"group_ids"`
+	RPMLimit         int                       `gorm:"not null;default:0" json:"rpm_limit"`
+	TPMLimit         int                       `gorm:"not null;default:0" json:"tpm_limit"`
+	ConcurrencyLimit int                       `gorm:"not null;default:0" json:"concurrency_limit"`
+	Priority         string                    `gorm:"type:varchar(20);not null;default:''" json:"priority"` // 'high', 'normal' or 'low'，为空时按 normal 处理
+	AllowedModels    string                    `gorm:"type:text" json:"allowed_models"`                      // 模型通配符列表，为空时不限制
+	MaxOutputTokens  int                       `gorm:"not null;default:0" json:"max_output_tokens"`
+	DenyTools        bool                      `gorm:"not null;default:false" json:"deny_tools"`
+	DenyVision       bool                      `gorm:"not null;default:false" json:"deny_vision"`
+	QuotaPeriod      string                    `gorm:"type:varchar(20);not null;default:''" json:"quota_period"` // '', 'daily', 'weekly', 'monthly' or 'total'
+	TokenQuota       int64                     `gorm:"not null;default:0" json:"token_quota"`
+	SpendQuota       float64                   `gorm:"not null;default:0" json:"spend_quota"`
+	ExpiresAt        *time.Time                `json:"expires_at"`
+	CreatedAt        time.Time                 `json:"created_at"`
+	UpdatedAt        time.Time                 `json:"updated_at"`
+}
+
+// IsExpired reports whether the key has passed its expiry time.
+func (k *ProxyKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// AllowsGroup reports whether the key grants access to the group.
+func (k *ProxyKey) AllowsGroup(groupID uint) bool {
+	if k.AllGroups {
+		return true
+	}
+	for _, id := range k.GroupIDs {
+		if id == groupID {
+			return true
+		}
+	}
+	return false
 }
 
 // RequestType 请求类型常量
@@ -142,26 +229,33 @@ const (
 
 // RequestLog 对应 request_logs 表
 type RequestLog struct {
-	ID              string    `gorm:"type:varchar(36);primaryKey" json:"id"`
-	Timestamp       time.Time `gorm:"not null;index" json:"timestamp"`
-	GroupID         uint      `gorm:"not null;index" json:"group_id"`
-	GroupName       string    `gorm:"type:varchar(255);index" json:"group_name"`
-	ParentGroupID   uint      `gorm:"index" json:"parent_group_id"`
-	ParentGroupName string    `gorm:"type:varchar(255);index" json:"parent_group_name"`
-	KeyValue        string    `gorm:"type:text" json:"key_value"`
-	KeyHash         string    `gorm:"type:varchar(128);index" json:"key_hash"`
-	Model           string    `gorm:"type:varchar(255);index" json:"model"`
-	IsSuccess       bool      `gorm:"not null" json:"is_success"`
-	SourceIP        string    `gorm:"type:varchar(64)" json:"source_ip"`
-	StatusCode      int       `gorm:"not null" json:"status_code"`
-	RequestPath     string    `gorm:"type:varchar(500)" json:"request_path"`
-	Duration        int64     `gorm:"not null" json:"duration_ms"`
-	ErrorMessage    string    `gorm:"type:text" json:"error_message"`
-	UserAgent       string    `gorm:"type:varchar(512)" json:"user_agent"`
-	RequestType     string    `gorm:"type:varchar(20);not null;default:'final';index" json:"request_type"`
-	UpstreamAddr    string    `gorm:"type:varchar(500)" json:"upstream_addr"`
-	IsStream        bool      `gorm:"not null" json:"is_stream"`
-	RequestBody     string    `gorm:"type:text" json:"request_body"`
+	ID                string    `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Timestamp         time.Time `gorm:"not null;index" json:"timestamp"`
+	GroupID           uint      `gorm:"not null;index" json:"group_id"`
+	GroupName         string    `gorm:"type:varchar(255);index" json:"group_name"`
+	ParentGroupID     uint      `gorm:"index" json:"parent_group_id"`
+	ParentGroupName   string    `gorm:"type:varchar(255);index" json:"parent_group_name"`
+	KeyValue          string    `gorm:"type:text" json:"key_value"`
+	KeyHash           string    `gorm:"type:varchar(128);index" json:"key_hash"`
+	Model             string    `gorm:"type:varchar(255);index" json:"model"`
+	IsSuccess         bool      `gorm:"not null" json:"is_success"`
+	SourceIP          string    `gorm:"type:varchar(64)" json:"source_ip"`
+	StatusCode        int       `gorm:"not null" json:"status_code"`
+	RequestPath       string    `gorm:"type:varchar(500)" json:"request_path"`
+	Duration          int64     `gorm:"not null" json:"duration_ms"`
+	ErrorMessage      string    `gorm:"type:text" json:"error_message"`
+	UserAgent         string    `gorm:"type:varchar(512)" json:"user_agent"`
+	RequestType       string    `gorm:"type:varchar(20);not null;default:'final';index" json:"request_type"`
+	UpstreamAddr      string    `gorm:"type:varchar(500)" json:"upstream_addr"`
+	IsStream          bool      `gorm:"not null" json:"is_stream"`
+	RequestBody       string    `gorm:"type:text" json:"request_body"`
+	PromptTokens      int64     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens  int64     `gorm:"not null;default:0" json:"completion_tokens"`
+	CachedTokens      int64     `gorm:"not null;default:0" json:"cached_tokens"`
+	ReasoningTokens   int64     `gorm:"not null;default:0" json:"reasoning_tokens"`
+	Cost              float64   `gorm:"not null;default:0" json:"cost"`
+	TruncatedMessages int       `gorm:"not null;default:0" json:"truncated_messages"`
+	ProxyKeyID        uint      `gorm:"not null;default:0;index" json:"proxy_key_id"`
 }
 
 // StatCard 用于仪表盘的单个统计卡片数据
@@ -187,14 +281,16 @@ type DashboardStatsResponse struct {
 	RPM              StatCard          `json:"rpm"`
 	RequestCount     StatCard          `json:"request_count"`
 	ErrorRate        StatCard          `json:"error_rate"`
+	Cost             StatCard          `json:"cost"`
+	KeyStatusCounts  map[string]int64  `json:"key_status_counts"` // 除 active 和 invalid 外各状态的密钥数量
 	SecurityWarnings []SecurityWarning `json:"security_warnings"`
 }
 
 // ChartDataset 用于图表的数据集
 type ChartDataset struct {
-	Label string  `json:"label"`
-	Data  []int64 `json:"data"`
-	Color string  `json:"color"`
+	Label string    `json:"label"`
+	Data  []float64 `json:"data"`
+	Color string    `json:"color"`
 }
 
 // ChartData 用于图表的API响应
@@ -203,6 +299,34 @@ type ChartData struct {
 	Datasets []ChartDataset `json:"datasets"`
 }
 
+// CostBreakdownItem 费用统计中单个分组或模型的汇总
+type CostBreakdownItem struct {
+	Name             string  `json:"name"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// ProxyKeyUsageItem 仪表盘中单个代理密钥的请求、错误、Token 与费用汇总
+type ProxyKeyUsageItem struct {
+	ProxyKeyID       uint    `json:"proxy_key_id"`
+	Name             string  `json:"name"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// CostSummaryResponse 用于仪表盘费用统计的API响应
+type CostSummaryResponse struct {
+	TotalCost float64             `json:"total_cost"`
+	ByGroup   []CostBreakdownItem `json:"by_group"`
+	ByModel   []CostBreakdownItem `json:"by_model"`
+	Trend     ChartData           `json:"trend"`
+}
+
 // GroupHourlyStat 对应 group_hourly_stats 表，用于存储每个分组每小时的请求统计
 type GroupHourlyStat struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -213,3 +337,32 @@ type GroupHourlyStat struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// ProxyKeyDailyUsage 对应 proxy_key_daily_usages 表，按天汇总代理密钥的用量，用于配额统计
+type ProxyKeyDailyUsage struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProxyKeyID   uint      `gorm:"not null;uniqueIndex:idx_proxy_key_date" json:"proxy_key_id"`
+	Date         time.Time `gorm:"not null;uniqueIndex:idx_proxy_key_date" json:"date"` // 当天零点
+	RequestCount int64     `gorm:"not null;default:0" json:"request_count"`
+	Tokens       int64     `gorm:"not null;default:0" json:"tokens"`
+	Cost         float64   `gorm:"not null;default:0" json:"cost"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// GroupModelHourlyStat 对应 group_model_hourly_stats 表，按分组和模型存储每小时的请求、Token、费用及耗时统计
+type GroupModelHourlyStat struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Time             time.Time `gorm:"not null;uniqueIndex:idx_group_model_time" json:"time"` // 整点时间
+	GroupID          uint      `gorm:"not null;uniqueIndex:idx_group_model_time" json:"group_id"`
+	Model            string    `gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_group_model_time" json:"model"`
+	SuccessCount     int64     `gorm:"not null;default:0" json:"success_count"`
+	FailureCount     int64     `gorm:"not null;default:0" json:"failure_count"`
+	PromptTokens     int64     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int64     `gorm:"not null;default:0" json:"completion_tokens"`
+	Cost             float64   `gorm:"not null;default:0" json:"cost"`
+	LatencySum       int64     `gorm:"not null;default:0" json:"latency_sum_ms"`
+	LatencyCount     int64     `gorm:"not null;default:0" json:"latency_count"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"fmt"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/tokenizer"
+	"gpt-load/internal/utils"
+)
+
+// checkInputTokens estimates the input tokens of a request and compares them with the group's limit
+// for the model. It returns an error message when the request is over the limit.
+func checkInputTokens(group *models.Group, model string, bodyBytes []byte) (string, bool) {
+	rules := group.EffectiveConfig.MaxInputTokens
+	if rules == "" {
+		return "", true
+	}
+	limits := utils.ParseModelLimits(rules)
+
+	limit, ok := utils.FindModelLimit(limits, model)
+	if !ok {
+		return "", true
+	}
+
+	estimated := tokenizer.EstimateRequest(bodyBytes)
+	if estimated <= limit {
+		return "", true
+	}
+	return fmt.Sprintf("This model's maximum input length is %d tokens. However, your request is estimated at %d tokens. Please reduce the length of the messages.", limit, estimated), false
+}
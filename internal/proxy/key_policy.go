@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/services"
+	"gpt-load/internal/tokenizer"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkProxyKeyPolicy enforces the model allow-list and request restrictions of the proxy key that
+// authenticated the request. Requests authorized by a legacy proxy key string are not restricted.
+// Output token ceilings are applied later by limitOutputTokens.
+// It returns an error code and message when the request is not allowed.
+func checkProxyKeyPolicy(c *gin.Context, requestedModel string, bodyBytes []byte) (string, string, bool) {
+	key := proxyKeyFromContext(c)
+	if key == nil {
+		return "", "", true
+	}
+
+	if code, message, ok := checkProxyKeyModel(key, requestedModel); !ok {
+		return code, message, false
+	}
+
+	if key.MaxOutputTokens <= 0 && !key.DenyTools && !key.DenyVision {
+		return "", "", true
+	}
+	var request map[string]any
+	if err := json.Unmarshal(bodyBytes, &request); err != nil {
+		return "", "", true
+	}
+
+	// Uploads are forwarded as they are, so their ceiling cannot be lowered and is checked instead
+	if key.MaxOutputTokens > 0 && spooledBodyFromContext(c) != nil {
+		if requested, ok := requestedOutputTokens(request); ok && requested > key.MaxOutputTokens {
+			return "max_tokens_not_allowed", fmt.Sprintf("This key allows at most %d output tokens per request, but %d were requested.", key.MaxOutputTokens, requested), false
+		}
+	}
+
+	if key.DenyTools {
+		for _, field := range []string{"tools", "functions"} {
+			if tools, ok := request[field].([]any); ok && len(tools) > 0 {
+				return "tools_not_allowed", "This key is not allowed to use tools.", false
+			}
+		}
+	}
+
+	if key.DenyVision {
+		for _, field := range []string{"messages", "contents", "input"} {
+			if tokenizer.ContainsImage(request[field]) {
+				return "vision_not_allowed", "This key is not allowed to send images.", false
+			}
+		}
+	}
+
+	return "", "", true
+}
+
+// checkWebSocketPolicy enforces the proxy key restrictions on a WebSocket upgrade, where the model is named
+// in the model query parameter. The frames of a session are relayed without inspection, so keys with
+// output, tool or image restrictions cannot open one.
+func checkWebSocketPolicy(c *gin.Context) (string, string, bool) {
+	key := proxyKeyFromContext(c)
+	if key == nil {
+		return "", "", true
+	}
+
+	if code, message, ok := checkProxyKeyModel(key, c.Query("model")); !ok {
+		return code, message, false
+	}
+	if key.MaxOutputTokens > 0 || key.DenyTools || key.DenyVision {
+		return "websocket_not_allowed", "This key has request restrictions that cannot be enforced on WebSocket sessions.", false
+	}
+	return "", "", true
+}
+
+// checkProxyKeyModel checks the model against the key's allow-list. A key limited to some models
+// cannot be used for requests that do not name one.
+func checkProxyKeyModel(key *models.ProxyKey, model string) (string, string, bool) {
+	if key.AllowedModels == "" {
+		return "", "", true
+	}
+	if model == "" {
+		return "model_required", "This key is limited to specific models, but the request does not name a model.", false
+	}
+	if !utils.IsModelAllowed(key.AllowedModels, model) {
+		return "model_not_allowed", fmt.Sprintf("This key is not allowed to use the model '%s'.", model), false
+	}
+	return "", "", true
+}
+
+// limitOutputTokens lowers the output token caps of the request to the ceiling of the proxy key, and
+// sets the cap when a text generation request does not name one.
+func limitOutputTokens(c *gin.Context, bodyBytes []byte, group *models.Group) []byte {
+	key := proxyKeyFromContext(c)
+	if key == nil || key.MaxOutputTokens <= 0 {
+		return bodyBytes
+	}
+
+	var request map[string]any
+	if err := json.Unmarshal(bodyBytes, &request); err != nil {
+		return bodyBytes
+	}
+
+	ceiling := float64(key.MaxOutputTokens)
+	clamp := func(fields map[string]any, names ...string) bool {
+		found := false
+		for _, name := range names {
+			if v, ok := fields[name].(float64); ok {
+				found = true
+				if v > ceiling {
+					fields[name] = key.MaxOutputTokens
+				}
+			}
+		}
+		return found
+	}
+
+	found := clamp(request, "max_tokens", "max_completion_tokens", "max_output_tokens")
+	for _, field := range []string{"generationConfig", "generation_config"} {
+		if config, ok := request[field].(map[string]any); ok {
+			found = clamp(config, "maxOutputTokens", "max_output_tokens") || found
+		}
+	}
+
+	if !found {
+		_, hasContents := request["contents"]
+		_, hasMessages := request["messages"]
+		_, hasPrompt := request["prompt"]
+		switch {
+		case hasContents:
+			config, _ := request["generationConfig"].(map[string]any)
+			if config == nil {
+				config = map[string]any{}
+				request["generationConfig"] = config
+			}
+			config["maxOutputTokens"] = key.MaxOutputTokens
+		case strings.HasSuffix(c.Request.URL.Path, "/responses"):
+			request["max_output_tokens"] = key.MaxOutputTokens
+		case hasMessages && group.EffectiveConfig.UseOpenAICompat:
+			request["max_completion_tokens"] = key.MaxOutputTokens
+		case hasMessages, hasPrompt:
+			request["max_tokens"] = key.MaxOutputTokens
+		default:
+			// Not a text generation request
+			return bodyBytes
+		}
+	}
+
+	limited, err := json.Marshal(request)
+	if err != nil {
+		return bodyBytes
+	}
+	return limited
+}
+
+// proxyKeyFromContext returns the proxy key record that authenticated the request, if any.
+func proxyKeyFromContext(c *gin.Context) *models.ProxyKey {
+	if value, ok := c.Get(services.ProxyKeyContextKey); ok {
+		if key, ok := value.(*models.ProxyKey); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// requestedOutputTokens returns the output token cap of an OpenAI, Anthropic or Gemini request.
+func requestedOutputTokens(request map[string]any) (int, bool) {
+	for _, field := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens"} {
+		if v, ok := request[field].(float64); ok {
+			return int(v), true
+		}
+	}
+	for _, field := range []string{"generationConfig", "generation_config"} {
+		config, ok := request[field].(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, name := range []string{"maxOutputTokens", "max_output_tokens"} {
+			if v, ok := config[name].(float64); ok {
+				return int(v), true
+			}
+		}
+	}
+	return 0, false
+}
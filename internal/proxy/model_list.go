@@ -0,0 +1,411 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+	"gpt-load/internal/store"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	modelListCacheTTL     = 5 * time.Minute
+	modelListMaxPages     = 10
+	modelListFormatOpenAI = "openai"
+	modelListFormatClaude = "anthropic"
+	modelListFormatGemini = "gemini"
+)
+
+// modelListFormat reports whether the request is a model listing the proxy answers itself,
+// and which channel-native format the answer should use.
+func modelListFormat(c *gin.Context, group *models.Group) (string, bool) {
+	if c.Request.Method != http.MethodGet {
+		return "", false
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(c.Request.URL.Path, "/proxy/"+group.Name), "/")
+	switch path {
+	case "/v1beta/models":
+		return modelListFormatGemini, true
+	case "/v1/models", "/v1beta/openai/models":
+		if group.ChannelType == "anthropic" {
+			return modelListFormatClaude, true
+		}
+		return modelListFormatOpenAI, true
+	}
+	return "", false
+}
+
+// handleModelList answers /v1/models style requests with the merged model list of the group and its sub-groups,
+// limited to the models the proxy key may use.
+func (ps *ProxyServer) handleModelList(c *gin.Context, group *models.Group, format string) {
+	upstreamPath := strings.TrimSuffix(strings.TrimPrefix(c.Request.URL.Path, "/proxy/"+group.Name), "/")
+	entries, apiErr := ps.groupModelEntries(c.Request.Context(), group, upstreamPath, format)
+	if apiErr != nil {
+		response.Error(c, apiErr)
+		return
+	}
+
+	if key := proxyKeyFromContext(c); key != nil && key.AllowedModels != "" {
+		allowed := make([]map[string]any, 0, len(entries))
+		for _, entry := range entries {
+			if utils.IsModelAllowed(key.AllowedModels, modelEntryID(entry)) {
+				allowed = append(allowed, entry)
+			}
+		}
+		entries = allowed
+	}
+
+	body, err := json.Marshal(buildModelListResponse(entries, format))
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to encode model list: %v", err)))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// HandleGatewayModelList answers GET /v1/models on the gateway with the models the given routes serve,
+// in the OpenAI format. The routes are those whose group the proxy key may access.
+func (ps *ProxyServer) HandleGatewayModelList(c *gin.Context, routes []utils.ModelRoute) {
+	key := proxyKeyFromContext(c)
+	merged := []map[string]any{}
+	seen := make(map[string]bool)
+	var lastErr *app_errors.APIError
+	fetched := false
+	for _, route := range routes {
+		group, err := ps.groupManager.GetGroupByName(route.Group)
+		if err != nil {
+			continue
+		}
+
+		upstreamPath, format := "/v1/models", modelListFormatOpenAI
+		switch group.ChannelType {
+		case "anthropic":
+			format = modelListFormatClaude
+		case "gemini":
+			upstreamPath, format = "/v1beta/models", modelListFormatGemini
+		}
+		entries, apiErr := ps.groupModelEntries(c.Request.Context(), group, upstreamPath, format)
+		if apiErr != nil {
+			lastErr = apiErr
+			continue
+		}
+		fetched = true
+
+		// Earlier routes win, as they do when routing requests
+		for _, entry := range entries {
+			id := modelEntryID(entry)
+			if id == "" || seen[id] || !utils.MatchModelPattern(route.Pattern, id) {
+				continue
+			}
+			if key != nil && !utils.IsModelAllowed(key.AllowedModels, id) {
+				continue
+			}
+			seen[id] = true
+			merged = append(merged, entry)
+		}
+	}
+
+	if !fetched && lastErr != nil {
+		response.Error(c, lastErr)
+		return
+	}
+
+	body, err := json.Marshal(buildModelListResponse(merged, modelListFormatOpenAI))
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to encode model list: %v", err)))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// groupModelEntries returns the merged model list entries of the group, cached per group and format.
+func (ps *ProxyServer) groupModelEntries(ctx context.Context, group *models.Group, upstreamPath, format string) ([]map[string]any, *app_errors.APIError) {
+	// The generation changes whenever groups or settings change, which retires the lists cached before
+	generation, err := ps.store.Get(services.ModelListGenerationKey)
+	if err != nil && err != store.ErrNotFound {
+		logrus.WithError(err).Warn("Failed to read model list cache generation")
+	}
+	cacheKey := fmt.Sprintf("model_list_entries:%d:%s:%s", group.ID, format, generation)
+	if cached, err := ps.store.Get(cacheKey); err == nil {
+		var entries []map[string]any
+		if err := json.Unmarshal(cached, &entries); err == nil {
+			return entries, nil
+		}
+		logrus.WithError(err).Warn("Failed to decode cached model list")
+	} else if err != store.ErrNotFound {
+		logrus.WithError(err).Warn("Failed to read model list cache")
+	}
+
+	var targets []*models.Group
+	if group.GroupType == "aggregate" {
+		for _, sg := range group.SubGroups {
+			subGroup, err := ps.groupManager.GetGroupByName(sg.SubGroupName)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{"aggregate_group": group.Name, "sub_group": sg.SubGroupName}).Warn("Sub-group not found while listing models")
+				continue
+			}
+			targets = append(targets, subGroup)
+		}
+	} else {
+		targets = []*models.Group{group}
+	}
+
+	var merged []map[string]any
+	seen := make(map[string]bool)
+	var lastErr error
+	fetched := false
+	for _, target := range targets {
+		entries, err := ps.fetchGroupModels(ctx, target, upstreamPath)
+		if err != nil {
+			lastErr = err
+			logrus.WithFields(logrus.Fields{"group": target.Name, "error": err}).Warn("Failed to fetch model list from upstream")
+			continue
+		}
+		fetched = true
+		if target.ID != group.ID {
+			entries = applyModelListRules(entries, target, format)
+		}
+		for _, entry := range entries {
+			id := modelEntryID(entry)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			merged = append(merged, entry)
+		}
+	}
+
+	if !fetched && len(targets) > 0 {
+		return nil, app_errors.NewAPIError(app_errors.ErrBadGateway, fmt.Sprintf("Failed to fetch model list: %v", lastErr))
+	}
+
+	merged = applyModelListRules(merged, group, format)
+
+	if body, err := json.Marshal(merged); err != nil {
+		logrus.WithError(err).Warn("Failed to encode model list for the cache")
+	} else if err := ps.store.Set(cacheKey, body, modelListCacheTTL); err != nil {
+		logrus.WithError(err).Warn("Failed to cache model list")
+	}
+
+	return merged, nil
+}
+
+// fetchGroupModels lists the models of a standard group using one of its keys, following pagination.
+func (ps *ProxyServer) fetchGroupModels(ctx context.Context, group *models.Group, upstreamPath string) ([]map[string]any, error) {
+	channelHandler, err := ps.channelFactory.GetChannel(group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	apiKey, releaseKey, err := ps.keyProvider.SelectKey(group)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseKey()
+
+	cfg := group.EffectiveConfig
+	var entries []map[string]any
+	pageToken := ""
+	for page := 0; page < modelListMaxPages; page++ {
+		query := url.Values{}
+		if upstreamPath == "/v1beta/models" {
+			query.Set("pageSize", "1000")
+		} else if group.ChannelType == "anthropic" {
+			query.Set("limit", "1000")
+		}
+		if pageToken != "" {
+			if group.ChannelType == "anthropic" {
+				query.Set("after_id", pageToken)
+			} else {
+				query.Set("pageToken", pageToken)
+			}
+		}
+		requestURL := &url.URL{Path: "/proxy/" + group.Name + upstreamPath, RawQuery: query.Encode()}
+
+		upstreamURL, err := channelHandler.BuildUpstreamURL(requestURL, group.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build upstream URL: %w", err)
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.RequestTimeout)*time.Second)
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, upstreamURL, nil)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if ua := strings.TrimSpace(cfg.UpstreamUserAgent); ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+		channelHandler.ModifyRequest(req, apiKey, group)
+		if len(group.HeaderRuleList) > 0 {
+			utils.ApplyHeaderRules(req, group.HeaderRuleList, utils.NewHeaderVariableContext(group, apiKey))
+		}
+
+		resp, err := channelHandler.GetHTTPClient().Do(req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read model list: %w", err)
+		}
+		body = handleGzipCompression(resp, body)
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			parsedError := app_errors.ParseUpstreamError(body)
+			if resp.StatusCode != http.StatusNotFound {
+				ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError)
+			}
+			return nil, fmt.Errorf("[status %d] %s", resp.StatusCode, parsedError)
+		}
+
+		var payload struct {
+			Data          []map[string]any `json:"data"`
+			Models        []map[string]any `json:"models"`
+			NextPageToken string           `json:"nextPageToken"`
+			HasMore       bool             `json:"has_more"`
+			LastID        string           `json:"last_id"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("failed to parse model list: %w", err)
+		}
+		entries = append(entries, payload.Data...)
+		entries = append(entries, payload.Models...)
+
+		switch {
+		case payload.NextPageToken != "":
+			pageToken = payload.NextPageToken
+		case payload.HasMore && payload.LastID != "":
+			pageToken = payload.LastID
+		default:
+			return entries, nil
+		}
+	}
+
+	return entries, nil
+}
+
+// applyModelListRules adds alias entries and drops models that are not on the group's allow-list.
+func applyModelListRules(entries []map[string]any, group *models.Group, format string) []map[string]any {
+	cfg := group.EffectiveConfig
+
+	if aliases := utils.ParseModelAliases(cfg.ModelAliases); len(aliases) > 0 {
+		byID := make(map[string]map[string]any, len(entries))
+		for _, entry := range entries {
+			byID[modelEntryID(entry)] = entry
+		}
+		for alias, target := range aliases {
+			if _, exists := byID[alias]; exists {
+				continue
+			}
+			entry := newModelEntry(alias, byID[target], format)
+			entries = append(entries, entry)
+			byID[alias] = entry
+		}
+	}
+
+	allowList := utils.SplitRules(cfg.ModelAllowList)
+	if len(allowList) == 0 {
+		return entries
+	}
+
+	filtered := entries[:0]
+	for _, entry := range entries {
+		if utils.MatchAnyModelPattern(allowList, modelEntryID(entry)) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// modelEntryID returns the bare model name of an OpenAI, Anthropic or Gemini model entry.
+func modelEntryID(entry map[string]any) string {
+	if id, ok := entry["id"].(string); ok && id != "" {
+		return id
+	}
+	if name, ok := entry["name"].(string); ok {
+		return strings.TrimPrefix(name, "models/")
+	}
+	return ""
+}
+
+// newModelEntry builds a list entry for an alias, copying the target's metadata when it is known.
+func newModelEntry(alias string, target map[string]any, format string) map[string]any {
+	entry := make(map[string]any, len(target)+3)
+	for k, v := range target {
+		entry[k] = v
+	}
+
+	switch format {
+	case modelListFormatGemini:
+		entry["name"] = "models/" + alias
+		entry["displayName"] = alias
+	case modelListFormatClaude:
+		entry["type"] = "model"
+		entry["id"] = alias
+		entry["display_name"] = alias
+		if _, ok := entry["created_at"]; !ok {
+			entry["created_at"] = time.Unix(0, 0).UTC().Format(time.RFC3339)
+		}
+	default:
+		entry["id"] = alias
+		entry["object"] = "model"
+		if _, ok := entry["created"]; !ok {
+			entry["created"] = 0
+		}
+		if _, ok := entry["owned_by"]; !ok {
+			entry["owned_by"] = "gpt-load"
+		}
+	}
+	return entry
+}
+
+// buildModelListResponse wraps the entries in the list envelope of the requested format.
+func buildModelListResponse(entries []map[string]any, format string) map[string]any {
+	if entries == nil {
+		entries = []map[string]any{}
+	}
+
+	switch format {
+	case modelListFormatGemini:
+		for _, entry := range entries {
+			if _, ok := entry["name"]; !ok {
+				entry["name"] = "models/" + modelEntryID(entry)
+			}
+		}
+		return map[string]any{"models": entries}
+	case modelListFormatClaude:
+		result := map[string]any{"data": entries, "has_more": false, "first_id": nil, "last_id": nil}
+		if len(entries) > 0 {
+			result["first_id"] = modelEntryID(entries[0])
+			result["last_id"] = modelEntryID(entries[len(entries)-1])
+		}
+		return result
+	default:
+		for _, entry := range entries {
+			if _, ok := entry["id"]; !ok {
+				entry["id"] = modelEntryID(entry)
+				entry["object"] = "model"
+			}
+		}
+		return map[string]any{"object": "list", "data": entries}
+	}
+}
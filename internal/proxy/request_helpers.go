@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,7 +30,7 @@ func isDeepEmptyText(s string) bool {
 	return s2 == ""
 }
 
-func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group) ([]byte, error) {
+func (ps *ProxyServer) applyParamOverrides(c *gin.Context, bodyBytes []byte, group *models.Group) ([]byte, error) {
 	if len(bodyBytes) == 0 {
 		return bodyBytes, nil
 	}
@@ -218,6 +219,18 @@ func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group
 	// Step 8: Append system prompt text if configured
 	ps.applySystemPromptAppend(requestData, group)
 
+	// Step 9: Trim the middle of long conversations to the token budget
+	if budget := group.EffectiveConfig.TruncationTokenBudget; budget > 0 {
+		if dropped := truncateMiddleMessages(requestData, budget, group.EffectiveConfig.TruncationKeepTurns); dropped > 0 {
+			logrus.WithFields(logrus.Fields{
+				"group":   group.Name,
+				"dropped": dropped,
+				"budget":  budget,
+			}).Info("Truncated middle messages of an oversized conversation")
+			c.Set(truncatedMessagesContextKey, dropped)
+		}
+	}
+
 	return json.Marshal(requestData)
 }
 
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	resourceKeyContextKey = "resource_key_id"
+
+	// resourceCaptureSize is how much of a creation response is inspected for the new resource ID.
+	resourceCaptureSize = 16 * 1024
+	// maxResourceLookups bounds the store lookups per request.
+	maxResourceLookups = 8
+)
+
+var (
+	// OpenAI files, batches, vector stores, assistants, threads and stored responses are owned by the key that created them.
+	resourceIDPattern         = regexp.MustCompile(`^(file-|batch_|vs_|asst_|thread_|resp_)[A-Za-z0-9_-]+$`)
+	referencedResourcePattern = regexp.MustCompile(`"((?:file-|batch_|vs_|asst_|thread_|resp_)[A-Za-z0-9_-]+)"`)
+	createdResourcePattern    = regexp.MustCompile(`"id"\s*:\s*"((?:file-|batch_|vs_|asst_|thread_|resp_)[A-Za-z0-9_-]+)"`)
+)
+
+// resourceBinding records which group and key created a resource.
+type resourceBinding struct {
+	GroupName string `json:"group_name"`
+	KeyID     uint   `json:"key_id"`
+}
+
+func resourceAffinityKey(groupID uint, resourceID string) string {
+	return fmt.Sprintf("resource_affinity:%d:%s", groupID, resourceID)
+}
+
+// referencedResourceIDs collects resource IDs from the request path and body, e.g. /v1/files/file-abc
+// or "previous_response_id": "resp_abc".
+func referencedResourceIDs(c *gin.Context, bodyBytes []byte) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if !seen[id] && len(ids) < maxResourceLookups {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, segment := range strings.Split(c.Request.URL.Path, "/") {
+		if resourceIDPattern.MatchString(segment) {
+			add(segment)
+		}
+	}
+	for _, match := range referencedResourcePattern.FindAllSubmatch(bodyBytes, -1) {
+		add(string(match[1]))
+	}
+	return ids
+}
+
+// findResourceBinding returns the binding of the first referenced resource that has one.
+func (ps *ProxyServer) findResourceBinding(c *gin.Context, originalGroup *models.Group, bodyBytes []byte) *resourceBinding {
+	if originalGroup.EffectiveConfig.ResourceAffinityHours <= 0 {
+		return nil
+	}
+
+	for _, id := range referencedResourceIDs(c, bodyBytes) {
+		raw, err := ps.store.Get(resourceAffinityKey(originalGroup.ID, id))
+		if err != nil {
+			if err != store.ErrNotFound {
+				logrus.WithError(err).Warn("Failed to read resource affinity")
+			}
+			continue
+		}
+
+		var binding resourceBinding
+		if err := json.Unmarshal(raw, &binding); err != nil {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{"resource": id, "group": binding.GroupName, "keyID": binding.KeyID}).Debug("Request pinned to resource owner key")
+		return &binding
+	}
+	return nil
+}
+
+// captureCreatedResource wraps the response body so the ID of a newly created resource can be recorded.
+func captureCreatedResource(c *gin.Context, originalGroup *models.Group, resp *http.Response) *resourceCapture {
+	if c.Request.Method != http.MethodPost || originalGroup.EffectiveConfig.ResourceAffinityHours <= 0 {
+		return nil
+	}
+	capture := &resourceCapture{body: resp.Body, gzipped: resp.Header.Get("Content-Encoding") == "gzip"}
+	resp.Body = capture
+	return capture
+}
+
+// recordResourceBinding stores the key that created the resource found in the captured response.
+func (ps *ProxyServer) recordResourceBinding(capture *resourceCapture, originalGroup, group *models.Group, apiKey *models.APIKey) {
+	if capture == nil {
+		return
+	}
+	match := createdResourcePattern.FindSubmatch(capture.Bytes())
+	if match == nil {
+		return
+	}
+
+	value, err := json.Marshal(resourceBinding{GroupName: group.Name, KeyID: apiKey.ID})
+	if err != nil {
+		return
+	}
+	ttl := time.Duration(originalGroup.EffectiveConfig.ResourceAffinityHours) * time.Hour
+	if err := ps.store.Set(resourceAffinityKey(originalGroup.ID, string(match[1])), value, ttl); err != nil {
+		logrus.WithError(err).Warn("Failed to record resource affinity")
+	}
+}
+
+// releaseResourceBindings forgets the bindings of resources removed by a successful DELETE.
+func (ps *ProxyServer) releaseResourceBindings(c *gin.Context, originalGroup *models.Group) {
+	if c.Request.Method != http.MethodDelete || originalGroup.EffectiveConfig.ResourceAffinityHours <= 0 {
+		return
+	}
+	segments := strings.Split(strings.TrimSuffix(c.Request.URL.Path, "/"), "/")
+	if last := segments[len(segments)-1]; resourceIDPattern.MatchString(last) {
+		if err := ps.store.Delete(resourceAffinityKey(originalGroup.ID, last)); err != nil {
+			logrus.WithError(err).Warn("Failed to release resource affinity")
+		}
+	}
+}
+
+// resourceCapture keeps the first bytes read from a response body.
+type resourceCapture struct {
+	body    io.ReadCloser
+	buf     bytes.Buffer
+	gzipped bool
+}
+
+func (r *resourceCapture) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if remaining := resourceCaptureSize - r.buf.Len(); n > 0 && remaining > 0 {
+		r.buf.Write(p[:min(n, remaining)])
+	}
+	return n, err
+}
+
+func (r *resourceCapture) Close() error {
+	return r.body.Close()
+}
+
+// Bytes returns the captured prefix, decompressed on a best-effort basis.
+func (r *resourceCapture) Bytes() []byte {
+	if !r.gzipped {
+		return r.buf.Bytes()
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(r.buf.Bytes()))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	decoded, _ := io.ReadAll(reader)
+	return decoded
+}
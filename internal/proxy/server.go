@@ -20,6 +20,7 @@ import (
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
 	"gpt-load/internal/services"
+	"gpt-load/internal/store"
 	"gpt-load/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -34,7 +35,9 @@ type ProxyServer struct {
 	settingsManager   *config.SystemSettingsManager
 	channelFactory    *channel.Factory
 	requestLogService *services.RequestLogService
+	proxyKeyLimiter   *services.ProxyKeyLimiter
 	encryptionSvc     encryption.Service
+	store             store.Store
 }
 
 // NewProxyServer creates a new proxy server
@@ -45,7 +48,9 @@ func NewProxyServer(
 	settingsManager *config.SystemSettingsManager,
 	channelFactory *channel.Factory,
 	requestLogService *services.RequestLogService,
+	proxyKeyLimiter *services.ProxyKeyLimiter,
 	encryptionSvc encryption.Service,
+	store store.Store,
 ) (*ProxyServer, error) {
 	return &ProxyServer{
 		keyProvider:       keyProvider,
@@ -54,7 +59,9 @@ func NewProxyServer(
 		settingsManager:   settingsManager,
 		channelFactory:    channelFactory,
 		requestLogService: requestLogService,
+		proxyKeyLimiter:   proxyKeyLimiter,
 		encryptionSvc:     encryptionSvc,
+		store:             store,
 	}, nil
 }
 
@@ -69,6 +76,11 @@ func (ps *ProxyServer) HandleProxy(c *gin.Context) {
 		return
 	}
 
+	if format, ok := modelListFormat(c, originalGroup); ok {
+		ps.handleModelList(c, originalGroup, format)
+		return
+	}
+
 	// Select sub-group if this is an aggregate group
 	subGroupName, err := ps.subGroupManager.SelectSubGroup(originalGroup)
 	if err != nil {
@@ -95,22 +107,104 @@ func (ps *ProxyServer) HandleProxy(c *gin.Context) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		logrus.Errorf("Failed to read request body: %v", err)
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+	if isWebSocketUpgrade(c.Request) {
+		ps.handleWebSocketProxy(c, channelHandler, originalGroup, group, startTime)
 		return
 	}
+
+	// Multipart and binary uploads (audio, images, files) are copied as-is to a temporary file on disk
+	// instead of being buffered in memory, and bodyBytes only carries their text form fields.
+	var bodyBytes []byte
+	var spooled *spooledBody
+	if isSpooledContentType(c.Request.Header.Get("Content-Type")) {
+		if maxMB := group.EffectiveConfig.MaxUploadSizeMB; maxMB > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxMB)<<20)
+		}
+		spooled, err = spoolRequestBody(c.Request)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				response.Error(c, app_errors.NewAPIError(app_errors.ErrRequestTooLarge, fmt.Sprintf("Request body exceeds the maximum upload size of %d MB", group.EffectiveConfig.MaxUploadSizeMB)))
+				return
+			}
+			logrus.Errorf("Failed to spool request body: %v", err)
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+			return
+		}
+		defer spooled.Close()
+		c.Set(spooledBodyContextKey, spooled)
+		bodyBytes = spooled.fieldsJSON
+	} else {
+		bodyBytes, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			logrus.Errorf("Failed to read request body: %v", err)
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+			return
+		}
+	}
 	c.Request.Body.Close()
 
-	finalBodyBytes, err := ps.applyParamOverrides(bodyBytes, group)
-	if err != nil {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to apply parameter overrides: %v", err)))
+	// Requests that reference a stateful resource must use the key that created it.
+	if binding := ps.findResourceBinding(c, originalGroup, bodyBytes); binding != nil {
+		if binding.GroupName != group.Name {
+			group, err = ps.groupManager.GetGroupByName(binding.GroupName)
+			if err != nil {
+				response.Error(c, app_errors.NewAPIError(app_errors.ErrKeyUnavailable, fmt.Sprintf("The group that owns this resource is no longer available: %s", binding.GroupName)))
+				return
+			}
+			channelHandler, err = ps.channelFactory.GetChannel(group)
+			if err != nil {
+				response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to get channel for group '%s': %v", group.Name, err)))
+				return
+			}
+		}
+		c.Set(resourceKeyContextKey, binding.KeyID)
+	} else if binding := ps.findSessionBinding(c, originalGroup, bodyBytes); binding != nil {
+		// Requests of the same session prefer the key they used last, to reuse the provider's prompt cache
+		if boundGroup := ps.sessionGroup(originalGroup, binding); boundGroup != nil && boundGroup.ID != group.ID {
+			if boundChannel, err := ps.channelFactory.GetChannel(boundGroup); err == nil {
+				group, channelHandler = boundGroup, boundChannel
+			}
+		}
+		if binding.GroupName == group.Name {
+			c.Set(sessionKeyContextKey, binding.KeyID)
+		}
+	}
+
+	requestedModel := channelHandler.ExtractModel(c, bodyBytes)
+	// Enforce the restrictions of the proxy key before a key is selected
+	if code, message, ok := checkProxyKeyPolicy(c, requestedModel, bodyBytes); !ok {
+		response.ChannelError(c, group.ChannelType, http.StatusForbidden, code, message)
+		ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusForbidden, errors.New(message), channelHandler.IsStreamRequest(c, bodyBytes), "", channelHandler, bodyBytes, models.RequestTypeFinal)
 		return
 	}
+	finalBodyBytes := bodyBytes
+	if spooled == nil {
+		finalBodyBytes, err = ps.applyParamOverrides(c, bodyBytes, group)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to apply parameter overrides: %v", err)))
+			return
+		}
+		// After the overrides, so that a group default cannot lift the cap above the key's ceiling
+		finalBodyBytes = limitOutputTokens(c, finalBodyBytes, group)
+
+		var injected bool
+		if finalBodyBytes, injected = injectStreamUsage(c, finalBodyBytes, group); injected {
+			c.Set(stripUsageChunkContextKey, true)
+		}
+	}
 
 	isStream := channelHandler.IsStreamRequest(c, bodyBytes)
 
+	// Reject oversized prompts before a key is spent on them
+	if spooled == nil {
+		if message, ok := checkInputTokens(group, requestedModel, finalBodyBytes); !ok {
+			response.ChannelError(c, group.ChannelType, http.StatusBadRequest, "context_length_exceeded", message)
+			ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusBadRequest, errors.New(message), isStream, "", channelHandler, finalBodyBytes, models.RequestTypeFinal)
+			return
+		}
+	}
+
 	ps.executeRequestWithRetry(c, channelHandler, originalGroup, group, finalBodyBytes, isStream, startTime, 0)
 }
 
@@ -127,11 +221,34 @@ func (ps *ProxyServer) executeRequestWithRetry(
 ) {
 	cfg := group.EffectiveConfig
 
-	apiKey, err := ps.keyProvider.SelectKey(group.ID)
+	var apiKey *models.APIKey
+	var err error
+	var releaseKey func()
+	if keyID, ok := c.Get(resourceKeyContextKey); ok {
+		apiKey, releaseKey, err = ps.keyProvider.GetActiveKey(group, keyID.(uint))
+	} else {
+		if keyID, ok := c.Get(sessionKeyContextKey); ok && retryCount == 0 {
+			// Keep the session on its key, falling back to normal selection once the key is gone
+			apiKey, releaseKey, _ = ps.keyProvider.GetSessionKey(group, keyID.(uint))
+		}
+		if apiKey == nil {
+			// Wait for a key to come back when the group is out of active keys
+			apiKey, releaseKey, err = ps.keyProvider.WaitForKey(c.Request.Context(), group, time.Duration(cfg.KeyWaitTimeout)*time.Second)
+		}
+	}
+	if releaseKey == nil {
+		releaseKey = func() {}
+	}
+	defer releaseKey()
 	if err != nil {
 		logrus.Errorf("Failed to select a key for group %s on attempt %d: %v", group.Name, retryCount+1, err)
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error()))
-		ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusServiceUnavailable, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
+		apiErr := app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error())
+		var keyErr *app_errors.APIError
+		if errors.As(err, &keyErr) && keyErr.Code == app_errors.ErrKeyUnavailable.Code {
+			apiErr = keyErr
+		}
+		response.Error(c, apiErr)
+		ps.logRequest(c, originalGroup, group, nil, startTime, apiErr.HTTPStatus, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
 		return
 	}
 
@@ -151,13 +268,20 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	}
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, c.Request.Method, upstreamURL, bytes.NewReader(bodyBytes))
+	var reqBody io.Reader = bytes.NewReader(bodyBytes)
+	contentLength := int64(len(bodyBytes))
+	if spooled := spooledBodyFromContext(c); spooled != nil {
+		reqBody = spooled.NewReader()
+		contentLength = spooled.size
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, upstreamURL, reqBody)
 	if err != nil {
 		logrus.Errorf("Failed to create upstream request: %v", err)
 		response.Error(c, app_errors.ErrInternalServer)
 		return
 	}
-	req.ContentLength = int64(len(bodyBytes))
+	req.ContentLength = contentLength
 
 	req.Header = c.Request.Header.Clone()
 	if ua := strings.TrimSpace(cfg.UpstreamUserAgent); ua != "" {
@@ -207,11 +331,13 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	if resp != nil {
 		defer resp.Body.Close()
 	}
+	rateLimited := false
 	if err == nil && resp != nil {
 		logrus.WithFields(logrus.Fields{
 			"status":  resp.StatusCode,
 			"headers": resp.Header,
 		}).Debug("upstream.response")
+		rateLimited = ps.keyProvider.ObserveRateLimits(apiKey, group, resp.StatusCode, resp.Header)
 	}
 
 	// Unified error handling for retries. Exclude 404 from being a retryable error.
@@ -246,8 +372,12 @@ func (ps *ProxyServer) executeRequestWithRetry(
 			logrus.Debugf("Request failed with status %d (attempt %d/%d) for key %s. Parsed Error: %s", statusCode, retryCount+1, cfg.MaxRetries, utils.MaskAPIKey(apiKey.KeyValue), parsedError)
 		}
 
-		// 使用解析后的错误信息更新密钥状态
-		ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError)
+		// 使用解析后的错误信息更新密钥状态，上游已告知重置时间的 429 不计为失败
+		if statusCode == http.StatusTooManyRequests && rateLimited {
+			logrus.Debugf("Key %s is rate limited upstream, not counting as failure", utils.MaskAPIKey(apiKey.KeyValue))
+		} else {
+			ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError)
+		}
 
 		// 判断是否为最后一次尝试
 		isLastAttempt := retryCount >= cfg.MaxRetries
@@ -269,6 +399,8 @@ func (ps *ProxyServer) executeRequestWithRetry(
 			return
 		}
 
+		// 释放本次使用的密钥后再重试
+		releaseKey()
 		ps.executeRequestWithRetry(c, channelHandler, originalGroup, group, bodyBytes, isStream, startTime, retryCount+1)
 		return
 	}
@@ -282,6 +414,11 @@ func (ps *ProxyServer) executeRequestWithRetry(
 		}
 	}
 	c.Status(resp.StatusCode)
+	capture := captureCreatedResource(c, originalGroup, resp)
+	usage := recordUsage(resp)
+	if usage != nil && usage.stream && c.GetBool(stripUsageChunkContextKey) {
+		resp.Body = newUsageChunkFilter(resp.Body)
+	}
 	logrus.Infof("Content-Type %s request logs.", resp.Header.Get("Content-Type"))
 	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
 		ps.handleStreamingResponse(c, resp)
@@ -295,6 +432,16 @@ func (ps *ProxyServer) executeRequestWithRetry(
 			logrus.WithField("body", utils.TruncateString(rbuf.String(), 65000)).Debug("upstream.response.body")
 		}
 	}
+	if resp.StatusCode < http.StatusBadRequest {
+		ps.recordResourceBinding(capture, originalGroup, group, apiKey)
+		ps.releaseResourceBindings(c, originalGroup)
+		ps.recordSessionBinding(c, originalGroup, group, apiKey)
+	}
+	if usage != nil {
+		if u := usage.Usage(); !u.IsZero() {
+			c.Set(tokenUsageContextKey, u)
+		}
+	}
 
 	ps.logRequest(c, originalGroup, group, apiKey, startTime, resp.StatusCode, nil, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal)
 }
@@ -351,6 +498,16 @@ func (ps *ProxyServer) logRequest(
 	if channelHandler != nil && bodyBytes != nil {
 		logEntry.Model = channelHandler.ExtractModel(c, bodyBytes)
 	}
+	if logEntry.Model == "" {
+		// Realtime WebSocket sessions carry the model in the query string
+		logEntry.Model = c.Query("model")
+	}
+
+	ps.applyTokenUsage(c, group, logEntry)
+	logEntry.TruncatedMessages = c.GetInt(truncatedMessagesContextKey)
+	if record, ok := c.Get(services.ProxyKeyContextKey); ok {
+		logEntry.ProxyKeyID = record.(*models.ProxyKey).ID
+	}
 
 	if apiKey != nil {
 		// 加密密钥值用于日志存储
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sessionIDContextKey  = "session_id"
+	sessionKeyContextKey = "session_key_id"
+)
+
+func sessionAffinityKey(groupID uint, sessionID string) string {
+	return fmt.Sprintf("session_affinity:%d:%s", groupID, sessionID)
+}
+
+// sessionAffinityConfig holds the session affinity settings of a group.
+type sessionAffinityConfig struct {
+	ttl      time.Duration
+	header   string
+	messages int
+}
+
+func sessionAffinityConfigOf(group *models.Group) sessionAffinityConfig {
+	cfg := group.EffectiveConfig
+	return sessionAffinityConfig{
+		ttl:      time.Duration(cfg.SessionAffinityMinutes) * time.Minute,
+		header:   strings.TrimSpace(cfg.SessionAffinityHeader),
+		messages: cfg.SessionAffinityMessages,
+	}
+}
+
+// requestSessionID identifies the conversation a request belongs to, so that it can keep using the same key
+// and hit the provider's prompt cache. It tries the configured header, then the end-user field of the body,
+// then a hash of the first messages. An empty string means the request has no session.
+func requestSessionID(c *gin.Context, cfg sessionAffinityConfig, bodyBytes []byte) string {
+	if cfg.header != "" {
+		if id := strings.TrimSpace(c.GetHeader(cfg.header)); id != "" {
+			return hashSessionID("header:" + id)
+		}
+	}
+
+	var request map[string]any
+	if err := json.Unmarshal(bodyBytes, &request); err != nil {
+		return ""
+	}
+
+	// OpenAI "user", Anthropic "metadata.user_id"
+	if user, ok := request["user"].(string); ok && user != "" {
+		return hashSessionID("user:" + user)
+	}
+	if metadata, ok := request["metadata"].(map[string]any); ok {
+		if user, ok := metadata["user_id"].(string); ok && user != "" {
+			return hashSessionID("user:" + user)
+		}
+	}
+
+	if cfg.messages <= 0 {
+		return ""
+	}
+	for _, field := range []string{"messages", "contents", "input"} {
+		messages, ok := request[field].([]any)
+		if !ok || len(messages) == 0 {
+			continue
+		}
+		prefix, err := json.Marshal(messages[:min(cfg.messages, len(messages))])
+		if err != nil {
+			return ""
+		}
+		return hashSessionID("messages:" + string(prefix))
+	}
+	return ""
+}
+
+func hashSessionID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// findSessionBinding returns the group and key the request's session last used, and remembers the session
+// so that the key finally used can be recorded.
+func (ps *ProxyServer) findSessionBinding(c *gin.Context, originalGroup *models.Group, bodyBytes []byte) *resourceBinding {
+	cfg := sessionAffinityConfigOf(originalGroup)
+	if cfg.ttl <= 0 {
+		return nil
+	}
+	sessionID := requestSessionID(c, cfg, bodyBytes)
+	if sessionID == "" {
+		return nil
+	}
+	c.Set(sessionIDContextKey, sessionID)
+
+	raw, err := ps.store.Get(sessionAffinityKey(originalGroup.ID, sessionID))
+	if err != nil {
+		if err != store.ErrNotFound {
+			logrus.WithError(err).Warn("Failed to read session affinity")
+		}
+		return nil
+	}
+
+	var binding resourceBinding
+	if err := json.Unmarshal(raw, &binding); err != nil {
+		return nil
+	}
+	logrus.WithFields(logrus.Fields{"session": sessionID, "group": binding.GroupName, "keyID": binding.KeyID}).Debug("Request pinned to session key")
+	return &binding
+}
+
+// sessionGroup returns the bound group if the request may still be routed to it: the group itself, or an
+// active sub-group of an aggregate group.
+func (ps *ProxyServer) sessionGroup(originalGroup *models.Group, binding *resourceBinding) *models.Group {
+	if binding.GroupName == originalGroup.Name {
+		return originalGroup
+	}
+	if originalGroup.GroupType != "aggregate" {
+		return nil
+	}
+	for _, sub := range originalGroup.SubGroups {
+		if sub.SubGroupName == binding.GroupName && sub.Weight > 0 {
+			group, err := ps.groupManager.GetGroupByName(binding.GroupName)
+			if err != nil {
+				return nil
+			}
+			return group
+		}
+	}
+	return nil
+}
+
+// recordSessionBinding stores the key that served the request's session, refreshing its TTL.
+func (ps *ProxyServer) recordSessionBinding(c *gin.Context, originalGroup, group *models.Group, apiKey *models.APIKey) {
+	sessionID := c.GetString(sessionIDContextKey)
+	if sessionID == "" {
+		return
+	}
+
+	value, err := json.Marshal(resourceBinding{GroupName: group.Name, KeyID: apiKey.ID})
+	if err != nil {
+		return
+	}
+	ttl := sessionAffinityConfigOf(originalGroup).ttl
+	if err := ps.store.Set(sessionAffinityKey(originalGroup.ID, sessionID), value, ttl); err != nil {
+		logrus.WithError(err).Warn("Failed to record session affinity")
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	spooledBodyContextKey = "spooled_body"
+
+	// maxFormFieldSize bounds how much of a text form field is kept in memory and in the field view.
+	maxFormFieldSize = 64 * 1024
+	// maxLoggedFieldSize bounds each text field in the log view.
+	maxLoggedFieldSize = 4 * 1024
+)
+
+// spooledBody holds a multipart or binary request body in a temporary file on disk, so it can be sent
+// upstream, and replayed on retries, without keeping uploads in memory.
+type spooledBody struct {
+	file *os.File
+	size int64
+
+	// fieldsJSON is a JSON object of the text form fields, used for model extraction and logging.
+	fieldsJSON []byte
+}
+
+// isSpooledContentType reports whether a body of this content type should be spooled instead of read into memory.
+func isSpooledContentType(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "application/json",
+		mediaType == "application/x-www-form-urlencoded",
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasPrefix(mediaType, "text/"):
+		return false
+	}
+	return true
+}
+
+// spoolRequestBody copies the request body unchanged to a temporary file, so that retries can replay it
+// without keeping uploads in memory. The text fields of a multipart body are collected while it is copied.
+func spoolRequestBody(req *http.Request) (*spooledBody, error) {
+	file, err := os.CreateTemp("", "gpt-load-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	sb := &spooledBody{file: file}
+
+	body := io.TeeReader(req.Body, file)
+	fields := map[string]any{}
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" && params["boundary"] != "" {
+		err = readFormFields(body, params["boundary"], fields)
+	}
+	if err == nil {
+		// Copies whatever the form reader has not consumed, or the whole body when it is not multipart
+		_, err = io.Copy(io.Discard, body)
+	}
+	if err != nil {
+		sb.Close()
+		return nil, err
+	}
+
+	if sb.size, err = file.Seek(0, io.SeekCurrent); err != nil {
+		sb.Close()
+		return nil, fmt.Errorf("failed to size spool file: %w", err)
+	}
+	if sb.fieldsJSON, err = json.Marshal(fields); err != nil {
+		sb.Close()
+		return nil, fmt.Errorf("failed to encode form fields: %w", err)
+	}
+	return sb, nil
+}
+
+// readFormFields collects a bounded copy of each text field of a multipart body. File parts are skipped.
+func readFormFields(body io.Reader, boundary string, fields map[string]any) error {
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read multipart body: %w", err)
+		}
+
+		name := part.FormName()
+		if part.FileName() != "" || name == "" {
+			continue
+		}
+
+		var value bytes.Buffer
+		if _, err := io.CopyN(&value, part, maxFormFieldSize+1); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read form field %s: %w", name, err)
+		}
+		if value.Len() > maxFormFieldSize {
+			fields[name] = utils.TruncateString(value.String(), maxLoggedFieldSize)
+			continue
+		}
+		fields[name] = formFieldValue(utils.TruncateString(value.String(), maxLoggedFieldSize))
+	}
+}
+
+// formFieldValue keeps booleans typed so flags such as "stream" are understood by the channel helpers.
+func formFieldValue(v string) any {
+	switch v {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return v
+}
+
+// NewReader returns an independent reader over the whole body.
+func (sb *spooledBody) NewReader() io.Reader {
+	return io.NewSectionReader(sb.file, 0, sb.size)
+}
+
+// Close removes the spool file.
+func (sb *spooledBody) Close() {
+	name := sb.file.Name()
+	sb.file.Close()
+	os.Remove(name)
+}
+
+// spooledBodyFromContext returns the spooled body of the request, if any.
+func spooledBodyFromContext(c *gin.Context) *spooledBody {
+	if v, ok := c.Get(spooledBodyContextKey); ok {
+		if sb, ok := v.(*spooledBody); ok {
+			return sb
+		}
+	}
+	return nil
+}
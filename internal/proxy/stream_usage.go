@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const stripUsageChunkContextKey = "strip_usage_chunk"
+
+// injectStreamUsage asks OpenAI-compatible upstreams to report usage on streaming requests.
+// It reports whether the client had not asked for usage itself, in which case the usage-only chunk must be stripped.
+func injectStreamUsage(c *gin.Context, bodyBytes []byte, group *models.Group) ([]byte, bool) {
+	if !group.EffectiveConfig.StreamUsageInjection {
+		return bodyBytes, false
+	}
+	path := strings.TrimSuffix(c.Request.URL.Path, "/")
+	if !strings.HasSuffix(path, "/chat/completions") && !strings.HasSuffix(path, "/completions") {
+		return bodyBytes, false
+	}
+
+	var requestData map[string]any
+	if err := json.Unmarshal(bodyBytes, &requestData); err != nil {
+		return bodyBytes, false
+	}
+	if stream, _ := requestData["stream"].(bool); !stream {
+		return bodyBytes, false
+	}
+
+	streamOptions, _ := requestData["stream_options"].(map[string]any)
+	if streamOptions == nil {
+		streamOptions = map[string]any{}
+	}
+	if includeUsage, _ := streamOptions["include_usage"].(bool); includeUsage {
+		return bodyBytes, false
+	}
+	streamOptions["include_usage"] = true
+	requestData["stream_options"] = streamOptions
+
+	modified, err := json.Marshal(requestData)
+	if err != nil {
+		return bodyBytes, false
+	}
+	return modified, true
+}
+
+// usageChunkFilter drops the trailing usage-only chunk ("choices": []) from an OpenAI stream,
+// passing every other line through as soon as it is complete.
+type usageChunkFilter struct {
+	body      io.ReadCloser
+	buf       []byte
+	line      []byte
+	out       bytes.Buffer
+	skipBlank bool
+	err       error
+}
+
+func newUsageChunkFilter(body io.ReadCloser) *usageChunkFilter {
+	return &usageChunkFilter{body: body, buf: make([]byte, 4*1024)}
+}
+
+func (f *usageChunkFilter) Read(p []byte) (int, error) {
+	for f.out.Len() == 0 && f.err == nil {
+		n, err := f.body.Read(f.buf)
+		f.feed(f.buf[:n])
+		if err != nil {
+			f.err = err
+			f.out.Write(f.line)
+			f.line = nil
+		}
+	}
+	if f.out.Len() > 0 {
+		return f.out.Read(p)
+	}
+	return 0, f.err
+}
+
+func (f *usageChunkFilter) Close() error {
+	return f.body.Close()
+}
+
+func (f *usageChunkFilter) feed(chunk []byte) {
+	for len(chunk) > 0 {
+		idx := bytes.IndexByte(chunk, '\n')
+		if idx < 0 {
+			f.line = append(f.line, chunk...)
+			return
+		}
+		f.line = append(f.line, chunk[:idx+1]...)
+		chunk = chunk[idx+1:]
+
+		trimmed := bytes.TrimSpace(f.line)
+		switch {
+		case f.skipBlank && len(trimmed) == 0:
+			f.skipBlank = false
+		case isUsageOnlyChunk(trimmed):
+			f.skipBlank = true
+		default:
+			f.skipBlank = false
+			f.out.Write(f.line)
+		}
+		f.line = f.line[:0]
+	}
+}
+
+func isUsageOnlyChunk(line []byte) bool {
+	if !bytes.HasPrefix(line, []byte("data:")) || !bytes.Contains(line, []byte(`"usage"`)) {
+		return false
+	}
+	var chunk struct {
+		Choices *[]json.RawMessage `json:"choices"`
+		Usage   json.RawMessage    `json:"usage"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(line[len("data:"):]), &chunk); err != nil {
+		return false
+	}
+	return chunk.Choices != nil && len(*chunk.Choices) == 0 && len(chunk.Usage) > 0 && string(chunk.Usage) != "null"
+}
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"encoding/json"
+
+	"gpt-load/internal/tokenizer"
+)
+
+const truncatedMessagesContextKey = "truncated_messages"
+
+// truncateMiddleMessages drops turns from the middle of the conversation until the estimated input
+// fits in budget. The system prompt, the first user turn and the last keepTurns turns are always kept,
+// and a tool call is only ever dropped together with its results. It returns the number of messages dropped.
+func truncateMiddleMessages(requestData map[string]any, budget, keepTurns int) int {
+	if budget <= 0 {
+		return 0
+	}
+
+	field := ""
+	for _, f := range []string{"messages", "contents", "input"} {
+		if _, ok := requestData[f].([]any); ok {
+			field = f
+			break
+		}
+	}
+	if field == "" {
+		return 0
+	}
+	messages := requestData[field].([]any)
+
+	raw, err := json.Marshal(requestData)
+	if err != nil {
+		return 0
+	}
+	estimated := tokenizer.EstimateRequest(raw)
+	if estimated <= budget {
+		return 0
+	}
+
+	turns := groupTurns(messages)
+
+	// Leading system messages and the first user turn are pinned
+	head := 0
+	for head < len(turns) {
+		role := messageRole(messages[turns[head][0]])
+		head++
+		if role == "user" {
+			break
+		}
+	}
+	tail := max(len(turns)-keepTurns, head)
+
+	dropped := make(map[int]bool)
+	for t := head; t < tail && estimated > budget; t++ {
+		for _, i := range turns[t] {
+			estimated -= tokenizer.EstimateMessage(messages[i])
+			dropped[i] = true
+		}
+	}
+	if len(dropped) == 0 {
+		return 0
+	}
+
+	kept := make([]any, 0, len(messages)-len(dropped))
+	for i, message := range messages {
+		if !dropped[i] {
+			kept = append(kept, message)
+		}
+	}
+	requestData[field] = kept
+	return len(dropped)
+}
+
+// groupTurns splits the conversation into turns, each a list of message indexes.
+// Tool results are attached to the turn of the call they answer, so dropping a turn never splits a pair.
+func groupTurns(messages []any) [][]int {
+	var turns [][]int
+	for i, message := range messages {
+		if len(turns) > 0 && continuesTurn(messages[i-1], message) {
+			turns[len(turns)-1] = append(turns[len(turns)-1], i)
+			continue
+		}
+		turns = append(turns, []int{i})
+	}
+	return turns
+}
+
+// continuesTurn reports whether message belongs to the same turn as the message before it.
+func continuesTurn(prev, message any) bool {
+	m, ok := message.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	switch m["type"] {
+	case "function_call_output", "custom_tool_call_output":
+		// Responses API tool output item
+		return true
+	case "function_call", "custom_tool_call":
+		// Parallel Responses API tool calls are answered after the last call
+		if p, ok := prev.(map[string]any); ok {
+			return p["type"] == "function_call" || p["type"] == "custom_tool_call"
+		}
+	}
+
+	// OpenAI Chat Completions tool message
+	if m["role"] == "tool" || m["role"] == "function" {
+		return true
+	}
+	// Anthropic tool_result blocks and Gemini functionResponse parts
+	for _, field := range []string{"content", "parts"} {
+		parts, _ := m[field].([]any)
+		for _, part := range parts {
+			p, ok := part.(map[string]any)
+			if !ok {
+				continue
+			}
+			if p["type"] == "tool_result" {
+				return true
+			}
+			if _, ok := p["functionResponse"]; ok {
+				return true
+			}
+			if _, ok := p["function_response"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func messageRole(message any) string {
+	if m, ok := message.(map[string]any); ok {
+		if role, ok := m["role"].(string); ok {
+			return role
+		}
+	}
+	return ""
+}
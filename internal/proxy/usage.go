@@ -0,0 +1,276 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/services"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tokenUsageContextKey = "token_usage"
+
+	// maxUsageBodySize bounds how much of a non-streaming response is kept for usage parsing.
+	maxUsageBodySize = 8 * 1024 * 1024
+	// maxUsageLineSize bounds a single SSE line kept while looking for usage.
+	maxUsageLineSize = 1024 * 1024
+)
+
+// tokenUsage is the channel-independent token accounting of one response.
+type tokenUsage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	CachedTokens     int64
+	ReasoningTokens  int64
+}
+
+// IsZero reports whether no usage was found.
+func (u tokenUsage) IsZero() bool {
+	return u == tokenUsage{}
+}
+
+// usageRecorder observes a response body while it is relayed to the client and extracts the token usage,
+// parsing SSE events as they pass and JSON bodies once they have been fully copied.
+type usageRecorder struct {
+	body    io.ReadCloser
+	stream  bool
+	gzipped bool
+
+	line     []byte
+	buf      bytes.Buffer
+	overflow bool
+	usage    tokenUsage
+}
+
+// recordUsage wraps the response body of a successful upstream response with a usageRecorder.
+func recordUsage(resp *http.Response) *usageRecorder {
+	contentType := resp.Header.Get("Content-Type")
+	stream := strings.Contains(contentType, "text/event-stream")
+	if !stream && !strings.Contains(contentType, "json") {
+		return nil
+	}
+	recorder := &usageRecorder{
+		body:    resp.Body,
+		stream:  stream,
+		gzipped: resp.Header.Get("Content-Encoding") == "gzip",
+	}
+	resp.Body = recorder
+	return recorder
+}
+
+func (r *usageRecorder) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		if r.stream && !r.gzipped {
+			r.scanLines(p[:n])
+		} else if !r.overflow {
+			if r.buf.Len()+n > maxUsageBodySize {
+				r.overflow = true
+				r.buf.Reset()
+			} else {
+				r.buf.Write(p[:n])
+			}
+		}
+	}
+	return n, err
+}
+
+func (r *usageRecorder) Close() error {
+	return r.body.Close()
+}
+
+// scanLines feeds complete SSE lines to the parser, keeping a partial trailing line for the next read.
+func (r *usageRecorder) scanLines(chunk []byte) {
+	for len(chunk) > 0 {
+		idx := bytes.IndexByte(chunk, '\n')
+		if idx < 0 {
+			if len(r.line)+len(chunk) <= maxUsageLineSize {
+				r.line = append(r.line, chunk...)
+			}
+			return
+		}
+		line := chunk[:idx]
+		if len(r.line) > 0 {
+			line = append(r.line, line...)
+			r.line = r.line[:0]
+		}
+		r.parseEvent(line)
+		chunk = chunk[idx+1:]
+	}
+}
+
+func (r *usageRecorder) parseEvent(line []byte) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	payload := bytes.TrimSpace(line[len("data:"):])
+	// Only events that carry usage are decoded, the rest of the stream passes untouched.
+	if !bytes.Contains(payload, []byte(`"usage`)) {
+		return
+	}
+	var event map[string]any
+	if err := json.Unmarshal(payload, &event); err == nil {
+		r.usage.merge(event)
+	}
+}
+
+// Usage returns the usage found in the response.
+func (r *usageRecorder) Usage() tokenUsage {
+	if r.stream && !r.gzipped {
+		if len(r.line) > 0 {
+			r.parseEvent(r.line)
+			r.line = nil
+		}
+		return r.usage
+	}
+	if r.overflow || r.buf.Len() == 0 {
+		return r.usage
+	}
+
+	body := r.buf.Bytes()
+	if r.gzipped {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return r.usage
+		}
+		defer reader.Close()
+		if body, err = io.ReadAll(reader); err != nil {
+			return r.usage
+		}
+	}
+	if r.stream {
+		r.scanLines(body)
+		r.parseEvent(r.line)
+		return r.usage
+	}
+
+	// Gemini streamGenerateContent without alt=sse returns a JSON array of chunks.
+	var chunks []map[string]any
+	if err := json.Unmarshal(body, &chunks); err == nil {
+		for _, chunk := range chunks {
+			r.usage.merge(chunk)
+		}
+		return r.usage
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(body, &obj); err == nil {
+		r.usage.merge(obj)
+	}
+	return r.usage
+}
+
+// merge reads the usage of an OpenAI, Anthropic or Gemini payload. Later non-zero values win,
+// since streamed usage is cumulative.
+func (u *tokenUsage) merge(payload map[string]any) {
+	if meta, ok := payload["usageMetadata"].(map[string]any); ok {
+		// Gemini
+		thoughts := usageInt(meta, "thoughtsTokenCount")
+		u.set(&u.PromptTokens, usageInt(meta, "promptTokenCount"))
+		u.set(&u.CompletionTokens, usageInt(meta, "candidatesTokenCount")+thoughts)
+		u.set(&u.CachedTokens, usageInt(meta, "cachedContentTokenCount"))
+		u.set(&u.ReasoningTokens, thoughts)
+		return
+	}
+
+	usage, ok := payload["usage"].(map[string]any)
+	if !ok {
+		// Anthropic message_start and Responses API stream events nest the usage
+		for _, field := range []string{"message", "response"} {
+			if inner, ok := payload[field].(map[string]any); ok {
+				if usage, ok = inner["usage"].(map[string]any); ok {
+					break
+				}
+			}
+		}
+	}
+	if usage == nil {
+		return
+	}
+
+	if _, ok := usage["prompt_tokens"]; ok {
+		// OpenAI Chat Completions
+		u.set(&u.PromptTokens, usageInt(usage, "prompt_tokens"))
+		u.set(&u.CompletionTokens, usageInt(usage, "completion_tokens"))
+		if details, ok := usage["prompt_tokens_details"].(map[string]any); ok {
+			u.set(&u.CachedTokens, usageInt(details, "cached_tokens"))
+		}
+		if details, ok := usage["completion_tokens_details"].(map[string]any); ok {
+			u.set(&u.ReasoningTokens, usageInt(details, "reasoning_tokens"))
+		}
+		return
+	}
+
+	// Anthropic Messages and OpenAI Responses. Anthropic reports cache reads and writes outside input_tokens.
+	cacheRead := usageInt(usage, "cache_read_input_tokens")
+	input := usageInt(usage, "input_tokens")
+	if input > 0 || cacheRead > 0 {
+		u.set(&u.PromptTokens, input+cacheRead+usageInt(usage, "cache_creation_input_tokens"))
+	}
+	u.set(&u.CompletionTokens, usageInt(usage, "output_tokens"))
+	u.set(&u.CachedTokens, cacheRead)
+	if details, ok := usage["input_tokens_details"].(map[string]any); ok {
+		u.set(&u.CachedTokens, usageInt(details, "cached_tokens"))
+	}
+	if details, ok := usage["output_tokens_details"].(map[string]any); ok {
+		u.set(&u.ReasoningTokens, usageInt(details, "reasoning_tokens"))
+	}
+}
+
+func (u *tokenUsage) set(field *int64, value int64) {
+	if value > 0 {
+		*field = value
+	}
+}
+
+func usageInt(m map[string]any, key string) int64 {
+	if v, ok := m[key].(float64); ok {
+		return int64(v)
+	}
+	return 0
+}
+
+// requestCost prices the usage of a request in USD using the price table and the group's multiplier.
+func requestCost(prices []utils.ModelPrice, model string, usage tokenUsage, multiplierPercent int) float64 {
+	price, ok := utils.FindModelPrice(prices, model)
+	if !ok {
+		return 0
+	}
+	cached := min(usage.CachedTokens, usage.PromptTokens)
+	cost := float64(usage.PromptTokens-cached)*price.Input +
+		float64(cached)*price.CachedInput +
+		float64(usage.CompletionTokens)*price.Output
+	return cost / 1e6 * float64(multiplierPercent) / 100
+}
+
+// applyTokenUsage copies the usage recorded for the request and its cost onto the log entry.
+func (ps *ProxyServer) applyTokenUsage(c *gin.Context, group *models.Group, logEntry *models.RequestLog) {
+	v, ok := c.Get(tokenUsageContextKey)
+	if !ok {
+		return
+	}
+	usage, ok := v.(tokenUsage)
+	if !ok {
+		return
+	}
+	logEntry.PromptTokens = usage.PromptTokens
+	logEntry.CompletionTokens = usage.CompletionTokens
+	logEntry.CachedTokens = usage.CachedTokens
+	logEntry.ReasoningTokens = usage.ReasoningTokens
+
+	if record, ok := c.Get(services.ProxyKeyContextKey); ok {
+		ps.proxyKeyLimiter.RecordTokens(record.(*models.ProxyKey), usage.PromptTokens+usage.CompletionTokens)
+	}
+
+	if prices := ps.settingsManager.GetSettings().ModelPrices; prices != "" {
+		logEntry.Cost = requestCost(utils.ParseModelPrices(prices), logEntry.Model, usage, group.EffectiveConfig.PriceMultiplierPercent)
+	}
+}
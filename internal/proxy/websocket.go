@@ -0,0 +1,459 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gpt-load/internal/channel"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// WebSocket opcodes and close codes used by the relay.
+const (
+	wsOpClose = 0x8
+
+	wsCloseNormal          = 1000
+	wsCloseGoingAway       = 1001
+	wsCloseNoStatus        = 1005
+	wsCloseAbnormal        = 1006
+	wsCloseInternalError   = 1011
+	wsMaxControlPayloadLen = 125
+)
+
+// isWebSocketUpgrade reports whether the request is a WebSocket handshake.
+func isWebSocketUpgrade(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket") &&
+		headerContainsToken(req.Header, "Connection", "upgrade")
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripWebSocketProtocolKey removes the API key subprotocol so the proxy key never reaches the upstream.
+func stripWebSocketProtocolKey(h http.Header) {
+	values := h.Values("Sec-WebSocket-Protocol")
+	if len(values) == 0 {
+		return
+	}
+
+	var kept []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			p := strings.TrimSpace(part)
+			if p == "" || strings.HasPrefix(p, utils.WebSocketProtocolKeyPrefix) {
+				continue
+			}
+			kept = append(kept, p)
+		}
+	}
+
+	h.Del("Sec-WebSocket-Protocol")
+	if len(kept) > 0 {
+		h.Set("Sec-WebSocket-Protocol", strings.Join(kept, ", "))
+	}
+}
+
+// handleWebSocketProxy performs the upstream handshake with a pooled key and relays frames until either side closes.
+func (ps *ProxyServer) handleWebSocketProxy(
+	c *gin.Context,
+	channelHandler channel.ChannelProxy,
+	originalGroup *models.Group,
+	group *models.Group,
+	startTime time.Time,
+) {
+	cfg := group.EffectiveConfig
+
+	if code, message, ok := checkWebSocketPolicy(c); !ok {
+		response.ChannelError(c, group.ChannelType, http.StatusForbidden, code, message)
+		ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusForbidden, errors.New(message), true, "", channelHandler, nil, models.RequestTypeFinal)
+		return
+	}
+
+	for retryCount := 0; ; retryCount++ {
+		apiKey, releaseKey, err := ps.keyProvider.SelectKey(group)
+		if err != nil {
+			logrus.Errorf("Failed to select a key for websocket in group %s on attempt %d: %v", group.Name, retryCount+1, err)
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error()))
+			ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusServiceUnavailable, err, true, "", channelHandler, nil, models.RequestTypeFinal)
+			return
+		}
+
+		upstreamURL, err := channelHandler.BuildUpstreamURL(c.Request.URL, originalGroup.Name)
+		if err != nil {
+			releaseKey()
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to build upstream URL: %v", err)))
+			return
+		}
+
+		req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, upstreamURL, nil)
+		if err != nil {
+			releaseKey()
+			logrus.Errorf("Failed to create upstream websocket request: %v", err)
+			response.Error(c, app_errors.ErrInternalServer)
+			return
+		}
+
+		req.Header = c.Request.Header.Clone()
+		if ua := strings.TrimSpace(cfg.UpstreamUserAgent); ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+		req.Header.Del("Authorization")
+		req.Header.Del("X-Api-Key")
+		req.Header.Del("X-Goog-Api-Key")
+		stripWebSocketProtocolKey(req.Header)
+
+		channelHandler.ModifyRequest(req, apiKey, group)
+
+		if len(group.HeaderRuleList) > 0 {
+			headerCtx := utils.NewHeaderVariableContextFromGin(c, group, apiKey)
+			utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+		}
+
+		resp, err := channelHandler.GetStreamClient().Do(req)
+		if err == nil && resp.StatusCode == http.StatusSwitchingProtocols {
+			// 握手成功的 key 在整个会话期间保持占用
+			defer releaseKey()
+			upstreamConn, ok := resp.Body.(io.ReadWriteCloser)
+			if !ok {
+				resp.Body.Close()
+				response.Error(c, app_errors.NewAPIError(app_errors.ErrBadGateway, "Upstream returned a non-writable websocket body"))
+				ps.logRequest(c, originalGroup, group, apiKey, startTime, http.StatusBadGateway, errors.New("upstream websocket body is not writable"), true, upstreamURL, channelHandler, nil, models.RequestTypeFinal)
+				return
+			}
+			ps.relayWebSocket(c, resp, upstreamConn, originalGroup, group, apiKey, upstreamURL, channelHandler, startTime)
+			return
+		}
+
+		// 握手失败的 key 立即释放，不占用到会话结束
+		releaseKey()
+
+		if err != nil && app_errors.IsIgnorableError(err) {
+			logrus.Debugf("Client-side ignorable error during websocket handshake for key %s: %v", utils.MaskAPIKey(apiKey.KeyValue), err)
+			ps.logRequest(c, originalGroup, group, apiKey, startTime, 499, err, true, upstreamURL, channelHandler, nil, models.RequestTypeFinal)
+			return
+		}
+
+		var statusCode int
+		var errorMessage, parsedError string
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+			errorMessage = err.Error()
+			parsedError = errorMessage
+		} else {
+			statusCode = resp.StatusCode
+			errorBody, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				errorBody = []byte("Failed to read error body")
+			}
+			errorBody = handleGzipCompression(resp, errorBody)
+			errorMessage = string(errorBody)
+			parsedError = app_errors.ParseUpstreamError(errorBody)
+			if statusCode < http.StatusBadRequest {
+				parsedError = fmt.Sprintf("unexpected websocket handshake status %d", statusCode)
+			}
+		}
+		logrus.Debugf("WebSocket handshake failed with status %d (attempt %d/%d) for key %s: %s", statusCode, retryCount+1, cfg.MaxRetries, utils.MaskAPIKey(apiKey.KeyValue), parsedError)
+
+		if statusCode >= http.StatusBadRequest && statusCode != http.StatusNotFound {
+			ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError)
+		}
+
+		isLastAttempt := retryCount >= cfg.MaxRetries || statusCode == http.StatusNotFound
+		requestType := models.RequestTypeRetry
+		if isLastAttempt {
+			requestType = models.RequestTypeFinal
+		}
+		ps.logRequest(c, originalGroup, group, apiKey, startTime, statusCode, errors.New(parsedError), true, upstreamURL, channelHandler, nil, requestType)
+
+		if isLastAttempt {
+			response.Error(c, app_errors.NewAPIErrorWithUpstream(statusCode, "UPSTREAM_ERROR", errorMessage))
+			return
+		}
+	}
+}
+
+// relayWebSocket hijacks the client connection, completes the handshake and pipes frames in both directions.
+func (ps *ProxyServer) relayWebSocket(
+	c *gin.Context,
+	resp *http.Response,
+	upstreamConn io.ReadWriteCloser,
+	originalGroup *models.Group,
+	group *models.Group,
+	apiKey *models.APIKey,
+	upstreamURL string,
+	channelHandler channel.ChannelProxy,
+	startTime time.Time,
+) {
+	defer upstreamConn.Close()
+
+	clientConn, clientBuf, err := c.Writer.Hijack()
+	if err != nil {
+		logrus.Errorf("Failed to hijack client connection for websocket: %v", err)
+		ps.logRequest(c, originalGroup, group, apiKey, startTime, http.StatusInternalServerError, err, true, upstreamURL, channelHandler, nil, models.RequestTypeFinal)
+		return
+	}
+	defer clientConn.Close()
+
+	// The server may have armed read/write timeouts on the connection; a realtime session outlives them.
+	clientConn.SetDeadline(time.Time{})
+
+	if err := writeSwitchingProtocols(clientBuf.Writer, resp.Header); err != nil {
+		logUpstreamError("writing websocket handshake to client", err)
+		ps.logRequest(c, originalGroup, group, apiKey, startTime, 499, err, true, upstreamURL, channelHandler, nil, models.RequestTypeFinal)
+		return
+	}
+
+	logrus.Debugf("WebSocket session established for group %s with key %s", group.Name, utils.MaskAPIKey(apiKey.KeyValue))
+
+	session := &webSocketSession{
+		client:   clientConn,
+		upstream: upstreamConn,
+	}
+	result := session.run(clientBuf.Reader)
+
+	statusCode := http.StatusSwitchingProtocols
+	var sessionErr error
+	if !result.isClean() {
+		sessionErr = errors.New(result.String())
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"group":       group.Name,
+		"duration_ms": time.Since(startTime).Milliseconds(),
+		"outcome":     result.String(),
+	}).Debug("WebSocket session closed")
+
+	ps.logRequest(c, originalGroup, group, apiKey, startTime, statusCode, sessionErr, true, upstreamURL, channelHandler, nil, models.RequestTypeFinal)
+}
+
+// writeSwitchingProtocols forwards the upstream 101 response to the hijacked client connection.
+func writeSwitchingProtocols(w *bufio.Writer, header http.Header) error {
+	if _, err := w.WriteString("HTTP/1.1 101 Switching Protocols\r\n"); err != nil {
+		return err
+	}
+	if err := header.Write(w); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// webSocketResult describes how a relayed session ended.
+type webSocketResult struct {
+	closedBy  string // "client" or "upstream"
+	closeCode int
+	err       error
+}
+
+func (r webSocketResult) isClean() bool {
+	if r.err != nil {
+		return false
+	}
+	switch r.closeCode {
+	case wsCloseNormal, wsCloseGoingAway, wsCloseNoStatus:
+		return true
+	}
+	// The client hanging up is never the upstream key's fault.
+	return r.closedBy == "client"
+}
+
+func (r webSocketResult) String() string {
+	msg := fmt.Sprintf("websocket closed by %s with code %d", r.closedBy, r.closeCode)
+	if r.err != nil {
+		msg += ": " + r.err.Error()
+	}
+	return msg
+}
+
+// webSocketSession relays frames between a hijacked client connection and an upstream connection.
+type webSocketSession struct {
+	client   net.Conn
+	upstream io.ReadWriteCloser
+
+	clientMu   sync.Mutex // serializes writes to the client
+	upstreamMu sync.Mutex // serializes writes to the upstream
+}
+
+func (s *webSocketSession) run(clientReader io.Reader) webSocketResult {
+	results := make(chan webSocketResult, 2)
+
+	go func() {
+		code, err := s.pipe(clientReader, s.upstream, &s.upstreamMu)
+		results <- webSocketResult{closedBy: "client", closeCode: code, err: ignorableOrNil(err)}
+	}()
+	go func() {
+		code, err := s.pipe(s.upstream, s.client, &s.clientMu)
+		results <- webSocketResult{closedBy: "upstream", closeCode: code, err: ignorableOrNil(err)}
+	}()
+
+	first := <-results
+
+	// Tell the peer that is still connected why the session ended, then tear both sides down.
+	if first.closedBy == "upstream" && first.closeCode == wsCloseAbnormal {
+		s.clientMu.Lock()
+		writeCloseFrame(s.client, wsCloseInternalError, "upstream connection lost", false)
+		s.clientMu.Unlock()
+	} else if first.closedBy == "client" && first.closeCode == wsCloseAbnormal {
+		s.upstreamMu.Lock()
+		writeCloseFrame(s.upstream, wsCloseGoingAway, "client disconnected", true)
+		s.upstreamMu.Unlock()
+	}
+
+	if first.closeCode != wsCloseAbnormal {
+		// A close frame was relayed; give the peer a moment to answer with its own close frame.
+		select {
+		case <-results:
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	s.client.Close()
+	s.upstream.Close()
+	return first
+}
+
+// pipe copies frames from src to dst until a close frame has been forwarded or the connection ends.
+// It returns the close code observed, or wsCloseAbnormal when the stream ended without one.
+func (s *webSocketSession) pipe(src io.Reader, dst io.Writer, dstMu *sync.Mutex) (int, error) {
+	header := make([]byte, 14)
+	for {
+		if _, err := io.ReadFull(src, header[:2]); err != nil {
+			return wsCloseAbnormal, eofOrNil(err)
+		}
+
+		opcode := header[0] & 0x0F
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7F)
+		n := 2
+
+		switch length {
+		case 126:
+			if _, err := io.ReadFull(src, header[n:n+2]); err != nil {
+				return wsCloseAbnormal, eofOrNil(err)
+			}
+			length = uint64(binary.BigEndian.Uint16(header[n : n+2]))
+			n += 2
+		case 127:
+			if _, err := io.ReadFull(src, header[n:n+8]); err != nil {
+				return wsCloseAbnormal, eofOrNil(err)
+			}
+			length = binary.BigEndian.Uint64(header[n : n+8])
+			n += 8
+		}
+
+		var maskKey []byte
+		if masked {
+			if _, err := io.ReadFull(src, header[n:n+4]); err != nil {
+				return wsCloseAbnormal, eofOrNil(err)
+			}
+			maskKey = header[n : n+4]
+			n += 4
+		}
+
+		if opcode == wsOpClose {
+			if length > wsMaxControlPayloadLen {
+				return wsCloseAbnormal, fmt.Errorf("close frame payload too large: %d", length)
+			}
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(src, payload); err != nil {
+				return wsCloseAbnormal, eofOrNil(err)
+			}
+
+			dstMu.Lock()
+			_, err := dst.Write(append(append([]byte{}, header[:n]...), payload...))
+			dstMu.Unlock()
+
+			return parseCloseCode(payload, maskKey), err
+		}
+
+		dstMu.Lock()
+		_, err := dst.Write(header[:n])
+		if err == nil {
+			_, err = io.CopyN(dst, src, int64(length))
+		}
+		dstMu.Unlock()
+		if err != nil {
+			return wsCloseAbnormal, eofOrNil(err)
+		}
+	}
+}
+
+// parseCloseCode extracts the status code from a close frame payload, unmasking it if needed.
+func parseCloseCode(payload, maskKey []byte) int {
+	if len(payload) < 2 {
+		return wsCloseNoStatus
+	}
+	code := make([]byte, 2)
+	copy(code, payload[:2])
+	if maskKey != nil {
+		code[0] ^= maskKey[0]
+		code[1] ^= maskKey[1]
+	}
+	return int(binary.BigEndian.Uint16(code))
+}
+
+// writeCloseFrame sends a close frame. Frames sent to a server must be masked.
+func writeCloseFrame(w io.Writer, code int, reason string, mask bool) {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > wsMaxControlPayloadLen {
+		payload = payload[:wsMaxControlPayloadLen]
+	}
+
+	frame := []byte{0x80 | wsOpClose, byte(len(payload))}
+	if mask {
+		maskKey := make([]byte, 4)
+		rand.Read(maskKey)
+		frame[1] |= 0x80
+		frame = append(frame, maskKey...)
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+	frame = append(frame, payload...)
+
+	if _, err := w.Write(frame); err != nil {
+		logrus.Debugf("Failed to send websocket close frame: %v", err)
+	}
+}
+
+func eofOrNil(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+func ignorableOrNil(err error) error {
+	if app_errors.IsIgnorableError(err) {
+		return nil
+	}
+	return err
+}
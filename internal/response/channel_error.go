@@ -0,0 +1,91 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChannelError aborts a request the proxy rejects itself with an error body in the native format
+// of the channel, so client SDKs surface it the same way as an upstream error.
+func ChannelError(c *gin.Context, channelType string, status int, code, message string) {
+	switch {
+	case channelType == "anthropic":
+		c.AbortWithStatusJSON(status, gin.H{
+			"type": "error",
+			"error": gin.H{
+				"type":    anthropicErrorType(status),
+				"message": message,
+			},
+		})
+	case channelType == "gemini" && !strings.Contains(c.Request.URL.Path, "/openai/"):
+		c.AbortWithStatusJSON(status, gin.H{
+			"error": gin.H{
+				"code":    status,
+				"message": message,
+				"status":  geminiErrorStatus(status),
+			},
+		})
+	default:
+		c.AbortWithStatusJSON(status, gin.H{
+			"error": gin.H{
+				"message": message,
+				"type":    openAIErrorType(status),
+				"param":   nil,
+				"code":    code,
+			},
+		})
+	}
+}
+
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_exceeded"
+	case status >= http.StatusInternalServerError:
+		return "server_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	default:
+		return "INTERNAL"
+	}
+}
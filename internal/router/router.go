@@ -2,6 +2,7 @@ package router
 
 import (
 	"embed"
+	"gpt-load/internal/config"
 	"gpt-load/internal/handler"
 	"gpt-load/internal/i18n"
 	"gpt-load/internal/middleware"
@@ -17,6 +18,7 @@ import (
 	"github.com/gin-contrib/static"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 type embedFileSystem struct {
@@ -43,6 +45,10 @@ func NewRouter(
 	proxyServer *proxy.ProxyServer,
 	configManager types.ConfigManager,
 	groupManager *services.GroupManager,
+	proxyKeyService *services.ProxyKeyService,
+	proxyKeyLimiter *services.ProxyKeyLimiter,
+	groupConcurrencyLimiter *services.GroupConcurrencyLimiter,
+	settingsManager *config.SystemSettingsManager,
 	buildFS embed.FS,
 	indexPage []byte,
 ) *gin.Engine {
@@ -50,6 +56,12 @@ func NewRouter(
 
 	router := gin.New()
 
+	// 仅信任配置的反向代理传递的客户端地址，gin 默认信任所有来源的 X-Forwarded-For
+	if err := router.SetTrustedProxies(configManager.GetEffectiveServerConfig().TrustedProxies); err != nil {
+		logrus.WithError(err).Error("Invalid trusted proxies, ignoring forwarded client addresses")
+		_ = router.SetTrustedProxies(nil)
+	}
+
 	// 注册全局中间件
 	router.Use(middleware.Recovery())
 	router.Use(middleware.ErrorHandler())
@@ -66,7 +78,8 @@ func NewRouter(
 	// 注册路由
 	registerSystemRoutes(router, serverHandler)
 	registerAPIRoutes(router, serverHandler, configManager)
-	registerProxyRoutes(router, proxyServer, groupManager, serverHandler)
+	registerProxyRoutes(router, proxyServer, groupManager, proxyKeyService, proxyKeyLimiter, groupConcurrencyLimiter, settingsManager, serverHandler)
+	registerGatewayRoutes(router, proxyServer, groupManager, proxyKeyService, proxyKeyLimiter, groupConcurrencyLimiter, settingsManager)
 	registerFrontendRoutes(router, buildFS, indexPage)
 
 	return router
@@ -101,6 +114,7 @@ func registerAPIRoutes(
 func registerPublicAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Server) {
 	api.POST("/auth/login", serverHandler.Login)
 	api.GET("/integration/info", serverHandler.GetIntegrationInfo)
+	api.GET("/proxy-key/quota", serverHandler.GetProxyKeyQuota)
 }
 
 // registerProtectedAPIRoutes 认证API路由
@@ -136,12 +150,24 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 		keys.POST("/delete-async", serverHandler.DeleteMultipleKeysAsync)
 		keys.POST("/restore-multiple", serverHandler.RestoreMultipleKeys)
 		keys.POST("/restore-all-invalid", serverHandler.RestoreAllInvalidKeys)
+		keys.POST("/update-weight", serverHandler.UpdateKeyWeights)
+		keys.POST("/update-status", serverHandler.UpdateKeyStatus)
+		keys.POST("/update-metadata", serverHandler.UpdateKeyMetadata)
 		keys.POST("/clear-all-invalid", serverHandler.ClearAllInvalidKeys)
 		keys.POST("/clear-all", serverHandler.ClearAllKeys)
 		keys.POST("/validate-group", serverHandler.ValidateGroupKeys)
 		keys.POST("/test-multiple", serverHandler.TestMultipleKeys)
 	}
 
+	// Proxy Key Management Routes
+	proxyKeys := api.Group("/proxy-keys")
+	{
+		proxyKeys.GET("", serverHandler.ListProxyKeys)
+		proxyKeys.POST("", serverHandler.CreateProxyKey)
+		proxyKeys.PUT("/:id", serverHandler.UpdateProxyKey)
+		proxyKeys.DELETE("/:id", serverHandler.DeleteProxyKey)
+	}
+
 	// Tasks
 	api.GET("/tasks/status", serverHandler.GetTaskStatus)
 
@@ -150,6 +176,8 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 	{
 		dashboard.GET("/stats", serverHandler.Stats)
 		dashboard.GET("/chart", serverHandler.Chart)
+		dashboard.GET("/cost", serverHandler.CostSummary)
+		dashboard.GET("/proxy-keys", serverHandler.ProxyKeyUsage)
 		dashboard.GET("/encryption-status", serverHandler.EncryptionStatus)
 	}
 
@@ -173,16 +201,44 @@ func registerProxyRoutes(
 	router *gin.Engine,
 	proxyServer *proxy.ProxyServer,
 	groupManager *services.GroupManager,
+	proxyKeyService *services.ProxyKeyService,
+	proxyKeyLimiter *services.ProxyKeyLimiter,
+	groupConcurrencyLimiter *services.GroupConcurrencyLimiter,
+	settingsManager *config.SystemSettingsManager,
 	serverHandler *handler.Server,
 ) {
 	proxyGroup := router.Group("/proxy/:group_name")
 
 	proxyGroup.Use(middleware.ProxyRouteDispatcher(serverHandler))
-	proxyGroup.Use(middleware.ProxyAuth(groupManager))
+	proxyGroup.Use(middleware.ProxyAuth(groupManager, proxyKeyService, settingsManager))
+	proxyGroup.Use(middleware.GroupConcurrency(groupManager, groupConcurrencyLimiter))
+	proxyGroup.Use(middleware.ProxyKeyLimits(groupManager, proxyKeyLimiter))
 
 	proxyGroup.Any("/*path", proxyServer.HandleProxy)
 }
 
+// registerGatewayRoutes 注册按模型路由的统一网关
+func registerGatewayRoutes(
+	router *gin.Engine,
+	proxyServer *proxy.ProxyServer,
+	groupManager *services.GroupManager,
+	proxyKeyService *services.ProxyKeyService,
+	proxyKeyLimiter *services.ProxyKeyLimiter,
+	groupConcurrencyLimiter *services.GroupConcurrencyLimiter,
+	settingsManager *config.SystemSettingsManager,
+) {
+	gatewayHandlers := []gin.HandlerFunc{
+		middleware.GatewayRouter(groupManager, proxyKeyService, settingsManager, proxyServer),
+		middleware.ProxyAuth(groupManager, proxyKeyService, settingsManager),
+		middleware.GroupConcurrency(groupManager, groupConcurrencyLimiter),
+		middleware.ProxyKeyLimits(groupManager, proxyKeyLimiter),
+		proxyServer.HandleProxy,
+	}
+
+	router.Any("/v1/*path", gatewayHandlers...)
+	router.Any("/v1beta/*path", gatewayHandlers...)
+}
+
 // registerFrontendRoutes 注册前端路由
 func registerFrontendRoutes(router *gin.Engine, buildFS embed.FS, indexPage []byte) {
 	router.Use(gzip.Gzip(gzip.DefaultCompression))
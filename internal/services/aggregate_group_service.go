@@ -158,11 +158,12 @@ func (s *AggregateGroupService) GetSubGroups(ctx context.Context, groupID uint)
 		}
 
 		subGroups = append(subGroups, models.SubGroupInfo{
-			Group:       subGroup,
-			Weight:      weightMap[subGroup.ID],
-			TotalKeys:   stats.TotalKeys,
-			ActiveKeys:  stats.ActiveKeys,
-			InvalidKeys: stats.InvalidKeys,
+			Group:           subGroup,
+			Weight:          weightMap[subGroup.ID],
+			TotalKeys:       stats.TotalKeys,
+			ActiveKeys:      stats.ActiveKeys,
+			InvalidKeys:     stats.InvalidKeys,
+			KeyStatusCounts: stats.StatusCounts,
 		})
 	}
 
@@ -380,11 +381,9 @@ func (s *AggregateGroupService) GetParentAggregateGroups(ctx context.Context, su
 
 // keyStatsResult stores key statistics for a single group
 type keyStatsResult struct {
-	GroupID     uint
-	TotalKeys   int64
-	ActiveKeys  int64
-	InvalidKeys int64
-	Err         error
+	GroupID uint
+	KeyStats
+	Err error
 }
 
 // fetchSubGroupsKeyStats batch fetches key statistics for multiple sub-groups concurrently
@@ -398,34 +397,8 @@ func (s *AggregateGroupService) fetchSubGroupsKeyStats(ctx context.Context, grou
 		go func(gid uint) {
 			defer wg.Done()
 
-			var totalKeys, activeKeys int64
-			result := keyStatsResult{GroupID: gid}
-
-			// Query total keys
-			if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
-				Where("group_id = ?", gid).
-				Count(&totalKeys).Error; err != nil {
-				result.Err = err
-				mu.Lock()
-				results[gid] = result
-				mu.Unlock()
-				return
-			}
-
-			// Query active keys
-			if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
-				Where("group_id = ? AND status = ?", gid, models.KeyStatusActive).
-				Count(&activeKeys).Error; err != nil {
-				result.Err = err
-				mu.Lock()
-				results[gid] = result
-				mu.Unlock()
-				return
-			}
-
-			result.TotalKeys = totalKeys
-			result.ActiveKeys = activeKeys
-			result.InvalidKeys = totalKeys - activeKeys
+			stats, err := CountKeysByStatus(s.db.WithContext(ctx).Where("group_id = ?", gid))
+			result := keyStatsResult{GroupID: gid, KeyStats: stats, Err: err}
 
 			mu.Lock()
 			results[gid] = result
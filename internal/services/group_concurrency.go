@@ -0,0 +1,165 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority classes of a proxy key. Queued requests of a higher class are admitted first.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// priorityRank orders the priority classes, 0 being the highest. Unknown classes count as normal.
+func priorityRank(priority string) int {
+	switch priority {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func isValidPriority(priority string) bool {
+	switch priority {
+	case "", PriorityHigh, PriorityNormal, PriorityLow:
+		return true
+	}
+	return false
+}
+
+// AdmissionError reports why a request was not admitted into a group.
+// QueueFull is set when the wait queue had no room; otherwise the request waited too long.
+type AdmissionError struct {
+	QueueFull bool
+	Limit     int
+}
+
+func (e *AdmissionError) Error() string {
+	if e.QueueFull {
+		return fmt.Sprintf("Too many concurrent requests for this group: the limit of %d is reached and the wait queue is full. Please try again later.", e.Limit)
+	}
+	return fmt.Sprintf("Too many concurrent requests for this group: timed out waiting for one of %d slots. Please try again later.", e.Limit)
+}
+
+// GroupConcurrencyLimiter bounds the in-flight requests of each group on this node. Requests over the
+// limit wait in a bounded queue ordered by priority class, then by arrival.
+type GroupConcurrencyLimiter struct {
+	mu    sync.Mutex
+	gates map[uint]*groupGate
+}
+
+// NewGroupConcurrencyLimiter creates a new GroupConcurrencyLimiter.
+func NewGroupConcurrencyLimiter() *GroupConcurrencyLimiter {
+	return &GroupConcurrencyLimiter{gates: make(map[uint]*groupGate)}
+}
+
+type groupGate struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiting int
+	queues  [3]list.List
+}
+
+type admissionWaiter struct {
+	ready chan struct{}
+}
+
+// Acquire admits a request into the group, waiting up to maxWait for a slot when the group is at its limit.
+// The returned release function must be called once the request has finished.
+func (l *GroupConcurrencyLimiter) Acquire(ctx context.Context, groupID uint, limit, queueSize int, maxWait time.Duration, priority string) (func(), *AdmissionError) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	gate := l.gate(groupID)
+
+	gate.mu.Lock()
+	gate.limit = limit
+	gate.admitWaiters()
+	if gate.active < limit {
+		gate.active++
+		gate.mu.Unlock()
+		return gate.releaseFunc(), nil
+	}
+	if gate.waiting >= queueSize || maxWait <= 0 {
+		gate.mu.Unlock()
+		return func() {}, &AdmissionError{QueueFull: true, Limit: limit}
+	}
+
+	waiter := &admissionWaiter{ready: make(chan struct{})}
+	queue := &gate.queues[priorityRank(priority)]
+	elem := queue.PushBack(waiter)
+	gate.waiting++
+	gate.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-waiter.ready:
+		return gate.releaseFunc(), nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	gate.mu.Lock()
+	select {
+	case <-waiter.ready:
+		// A slot was handed over while giving up; pass it on
+		gate.mu.Unlock()
+		gate.release()
+	default:
+		queue.Remove(elem)
+		gate.waiting--
+		gate.mu.Unlock()
+	}
+	return func() {}, &AdmissionError{Limit: limit}
+}
+
+func (l *GroupConcurrencyLimiter) gate(groupID uint) *groupGate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	gate, ok := l.gates[groupID]
+	if !ok {
+		gate = &groupGate{}
+		l.gates[groupID] = gate
+	}
+	return gate
+}
+
+func (g *groupGate) releaseFunc() func() {
+	var once sync.Once
+	return func() { once.Do(g.release) }
+}
+
+// release frees a slot and admits waiters while the group is under its limit.
+func (g *groupGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	g.admitWaiters()
+}
+
+// admitWaiters hands free slots to the first waiters of the highest priority classes.
+// The caller must hold g.mu.
+func (g *groupGate) admitWaiters() {
+	for g.active < g.limit && g.waiting > 0 {
+		for i := range g.queues {
+			if front := g.queues[i].Front(); front != nil {
+				g.queues[i].Remove(front)
+				g.waiting--
+				g.active++
+				close(front.Value.(*admissionWaiter).ready)
+				break
+			}
+		}
+	}
+}
@@ -16,6 +16,10 @@ import (
 
 const GroupUpdateChannel = "groups:updated"
 
+// ModelListGenerationKey is bumped on every group reload, so that model lists cached by the proxy
+// are rebuilt with the new aliases, allow-lists and sub-groups.
+const ModelListGenerationKey = "model_list:generation"
+
 // GroupManager manages the caching of group data.
 type GroupManager struct {
 	syncer          *syncer.CacheSyncer[map[string]*models.Group]
@@ -144,6 +148,9 @@ func (gm *GroupManager) Invalidate() error {
 	if gm.syncer == nil {
 		return fmt.Errorf("GroupManager is not initialized")
 	}
+	if _, err := gm.store.IncrBy(ModelListGenerationKey, 1); err != nil {
+		logrus.WithError(err).Warn("Failed to invalidate cached model lists")
+	}
 	return gm.syncer.Invalidate()
 }
 
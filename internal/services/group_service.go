@@ -57,6 +57,7 @@ type GroupService struct {
 	keyImportSvc          *KeyImportService
 	encryptionSvc         encryption.Service
 	aggregateGroupService *AggregateGroupService
+	proxyKeyService       *ProxyKeyService
 	channelRegistry       []string
 }
 
@@ -69,6 +70,7 @@ func NewGroupService(
 	keyImportSvc *KeyImportService,
 	encryptionSvc encryption.Service,
 	aggregateGroupService *AggregateGroupService,
+	proxyKeyService *ProxyKeyService,
 ) *GroupService {
 	return &GroupService{
 		db:                    db,
@@ -78,6 +80,7 @@ func NewGroupService(
 		keyImportSvc:          keyImportSvc,
 		encryptionSvc:         encryptionSvc,
 		aggregateGroupService: aggregateGroupService,
+		proxyKeyService:       proxyKeyService,
 		channelRegistry:       channel.GetChannels(),
 	}
 }
@@ -121,10 +124,37 @@ type GroupUpdateParams struct {
 }
 
 // KeyStats captures aggregated API key statistics for a group.
+// Only blacklisted keys count as invalid; the other inactive statuses are counted in StatusCounts.
 type KeyStats struct {
-	TotalKeys   int64 `json:"total_keys"`
-	ActiveKeys  int64 `json:"active_keys"`
-	InvalidKeys int64 `json:"invalid_keys"`
+	TotalKeys    int64            `json:"total_keys"`
+	ActiveKeys   int64            `json:"active_keys"`
+	InvalidKeys  int64            `json:"invalid_keys"`
+	StatusCounts map[string]int64 `json:"key_status_counts"`
+}
+
+// CountKeysByStatus counts the API keys matched by the query by status.
+func CountKeysByStatus(query *gorm.DB) (KeyStats, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := query.Model(&models.APIKey{}).Select("status, count(*) as count").Group("status").Scan(&rows).Error; err != nil {
+		return KeyStats{}, err
+	}
+
+	stats := KeyStats{StatusCounts: make(map[string]int64)}
+	for _, row := range rows {
+		stats.TotalKeys += row.Count
+		switch row.Status {
+		case models.KeyStatusActive:
+			stats.ActiveKeys = row.Count
+		case models.KeyStatusInvalid:
+			stats.InvalidKeys = row.Count
+		default:
+			stats.StatusCounts[row.Status] = row.Count
+		}
+	}
+	return stats, nil
 }
 
 // RequestStats captures request success and failure ratios over a time window.
@@ -240,6 +270,10 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 		return nil, app_errors.ParseDBError(err)
 	}
 
+	if err := s.proxyKeyService.ImportLegacyKeys(ctx, "", group.ProxyKeys, group.ID, group.Name); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to import group proxy keys")
+	}
+
 	if err := s.groupManager.Invalidate(); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
@@ -264,6 +298,8 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		return nil, app_errors.ParseDBError(err)
 	}
 
+	previousProxyKeys := group.ProxyKeys
+
 	tx := s.db.WithContext(ctx).Begin()
 	if err := tx.Error; err != nil {
 		return nil, app_errors.ErrDatabase
@@ -386,6 +422,10 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		return nil, app_errors.ErrDatabase
 	}
 
+	if err := s.proxyKeyService.ImportLegacyKeys(ctx, previousProxyKeys, group.ProxyKeys, group.ID, group.Name); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to import group proxy keys")
+	}
+
 	if err := s.groupManager.Invalidate(); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
@@ -519,6 +559,10 @@ func (s *GroupService) CopyGroup(ctx context.Context, sourceGroupID uint, copyKe
 	}
 	tx = nil
 
+	if err := s.proxyKeyService.ImportLegacyKeys(ctx, "", newGroup.ProxyKeys, newGroup.ID, newGroup.Name); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to import group proxy keys")
+	}
+
 	if err := s.groupManager.Invalidate(); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
@@ -580,25 +624,11 @@ func (s *GroupService) queryGroupHourlyStats(ctx context.Context, groupID uint,
 
 // fetchKeyStats retrieves API key statistics for a group
 func (s *GroupService) fetchKeyStats(ctx context.Context, groupID uint) (KeyStats, error) {
-	var totalKeys, activeKeys int64
-
-	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
-		Where("group_id = ?", groupID).
-		Count(&totalKeys).Error; err != nil {
-		return KeyStats{}, fmt.Errorf("failed to get total keys: %w", err)
-	}
-
-	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
-		Where("group_id = ? AND status = ?", groupID, models.KeyStatusActive).
-		Count(&activeKeys).Error; err != nil {
-		return KeyStats{}, fmt.Errorf("failed to get active keys: %w", err)
+	stats, err := CountKeysByStatus(s.db.WithContext(ctx).Where("group_id = ?", groupID))
+	if err != nil {
+		return KeyStats{}, fmt.Errorf("failed to count keys: %w", err)
 	}
-
-	return KeyStats{
-		TotalKeys:   totalKeys,
-		ActiveKeys:  activeKeys,
-		InvalidKeys: totalKeys - activeKeys,
-	}, nil
+	return stats, nil
 }
 
 // fetchRequestStats retrieves request statistics for multiple time periods
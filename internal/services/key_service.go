@@ -8,9 +8,12 @@ import (
 	"gpt-load/internal/models"
 	"io"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -40,6 +43,13 @@ type RestoreKeysResult struct {
 	TotalInGroup  int64 `json:"total_in_group"`
 }
 
+// UpdateKeysResult holds the result of setting the weight, status or metadata of multiple keys.
+type UpdateKeysResult struct {
+	UpdatedCount int   `json:"updated_count"`
+	IgnoredCount int   `json:"ignored_count"`
+	TotalInGroup int64 `json:"total_in_group"`
+}
+
 // KeyService provides services related to API keys.
 type KeyService struct {
 	DB            *gorm.DB
@@ -242,6 +252,139 @@ func (s *KeyService) RestoreMultipleKeys(groupID uint, keysText string) (*Restor
 	}, nil
 }
 
+// UpdateKeyWeights handles the business logic of setting the weight and priority of keys from a text block.
+func (s *KeyService) UpdateKeyWeights(groupID uint, keysText string, weight, priority int) (*UpdateKeysResult, error) {
+	return s.updateKeysFromText(groupID, keysText, func(chunk []string) (int64, error) {
+		return s.KeyProvider.UpdateKeyWeights(groupID, chunk, weight, priority)
+	})
+}
+
+// UpdateKeyStatus handles the business logic of setting the status of keys from a text block.
+// cooldownMinutes is only used for the cooldown status.
+func (s *KeyService) UpdateKeyStatus(groupID uint, keysText string, status string, cooldownMinutes int) (*UpdateKeysResult, error) {
+	var cooldownUntil *time.Time
+	if status == models.KeyStatusCooldown {
+		until := time.Now().Add(time.Duration(cooldownMinutes) * time.Minute)
+		cooldownUntil = &until
+	}
+
+	return s.updateKeysFromText(groupID, keysText, func(chunk []string) (int64, error) {
+		return s.KeyProvider.UpdateKeyStatus(groupID, chunk, status, cooldownUntil)
+	})
+}
+
+// KeyMetadataUpdate holds the metadata to set on keys. Nil fields are left unchanged.
+type KeyMetadataUpdate struct {
+	Name        *string
+	Notes       *string
+	Labels      *[]string
+	Source      *string
+	ExpiresAt   *time.Time
+	ClearExpiry bool
+}
+
+// UpdateKeyMetadata handles the business logic of setting the metadata of keys from a text block.
+func (s *KeyService) UpdateKeyMetadata(groupID uint, keysText string, metadata KeyMetadataUpdate) (*UpdateKeysResult, error) {
+	updates := make(map[string]any)
+	if metadata.Name != nil {
+		updates["name"] = strings.TrimSpace(*metadata.Name)
+	}
+	if metadata.Notes != nil {
+		updates["notes"] = *metadata.Notes
+	}
+	if metadata.Labels != nil {
+		updates["labels"] = datatypes.JSONSlice[string](normalizeKeyLabels(*metadata.Labels))
+	}
+	if metadata.Source != nil {
+		updates["source"] = strings.TrimSpace(*metadata.Source)
+	}
+	if metadata.ClearExpiry {
+		updates["expires_at"] = nil
+	} else if metadata.ExpiresAt != nil {
+		updates["expires_at"] = *metadata.ExpiresAt
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no metadata to update")
+	}
+
+	return s.updateKeysFromText(groupID, keysText, func(chunk []string) (int64, error) {
+		var keyHashes []string
+		for _, keyValue := range chunk {
+			if keyHash := s.EncryptionSvc.Hash(keyValue); keyHash != "" {
+				keyHashes = append(keyHashes, keyHash)
+			}
+		}
+		if len(keyHashes) == 0 {
+			return 0, nil
+		}
+
+		result := s.DB.Model(&models.APIKey{}).Where("group_id = ? AND key_hash IN ?", groupID, keyHashes).Updates(updates)
+		return result.RowsAffected, result.Error
+	})
+}
+
+// updateKeysFromText parses the keys of a text block and applies update to them chunk by chunk.
+// update returns how many keys of the chunk it changed; the other keys are counted as ignored.
+func (s *KeyService) updateKeysFromText(groupID uint, keysText string, update func(chunk []string) (int64, error)) (*UpdateKeysResult, error) {
+	keysToUpdate := s.ParseKeysFromText(keysText)
+	if len(keysToUpdate) > maxRequestKeys {
+		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keysToUpdate))
+	}
+	if len(keysToUpdate) == 0 {
+		return nil, fmt.Errorf("no valid keys found in the input text")
+	}
+
+	var totalUpdatedCount int64
+	for i := 0; i < len(keysToUpdate); i += chunkSize {
+		end := i + chunkSize
+		if end > len(keysToUpdate) {
+			end = len(keysToUpdate)
+		}
+		updatedCount, err := update(keysToUpdate[i:end])
+		if err != nil {
+			return nil, err
+		}
+		totalUpdatedCount += updatedCount
+	}
+
+	ignoredCount := len(keysToUpdate) - int(totalUpdatedCount)
+
+	var totalInGroup int64
+	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Count(&totalInGroup).Error; err != nil {
+		return nil, err
+	}
+
+	return &UpdateKeysResult{
+		UpdatedCount: int(totalUpdatedCount),
+		IgnoredCount: ignoredCount,
+		TotalInGroup: totalInGroup,
+	}, nil
+}
+
+// normalizeKeyLabels trims labels and drops empty and duplicate ones.
+func normalizeKeyLabels(labels []string) []string {
+	normalized := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label != "" && !slices.Contains(normalized, label) {
+			normalized = append(normalized, label)
+		}
+	}
+	return normalized
+}
+
+// whereKeyHasLabel filters keys whose labels contain the given label.
+func whereKeyHasLabel(query *gorm.DB, label string) *gorm.DB {
+	switch query.Dialector.Name() {
+	case "mysql":
+		return query.Where("JSON_CONTAINS(labels, JSON_ARRAY(?))", label)
+	case "postgres":
+		return query.Where("labels::jsonb @> jsonb_build_array(?)", label)
+	default:
+		return query.Where("EXISTS (SELECT 1 FROM json_each(labels) WHERE value = ?)", label)
+	}
+}
+
 // RestoreAllInvalidKeys sets the status of all 'inactive' keys in a group to 'active'.
 func (s *KeyService) RestoreAllInvalidKeys(groupID uint) (int64, error) {
 	return s.KeyProvider.RestoreKeys(groupID)
@@ -295,14 +438,18 @@ func (s *KeyService) DeleteMultipleKeys(groupID uint, keysText string) (*DeleteK
 	}, nil
 }
 
-// ListKeysInGroupQuery builds a query to list all keys within a specific group, filtered by status.
-func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, searchHash string) *gorm.DB {
+// ListKeysInGroupQuery builds a query to list all keys within a specific group, filtered by status and label.
+func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, searchHash string, label string) *gorm.DB {
 	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID)
 
 	if statusFilter != "" {
 		query = query.Where("status = ?", statusFilter)
 	}
 
+	if label != "" {
+		query = whereKeyHasLabel(query, label)
+	}
+
 	if searchHash != "" {
 		query = query.Where("key_hash = ?", searchHash)
 	}
@@ -340,13 +487,17 @@ func (s *KeyService) TestMultipleKeys(group *models.Group, keysText string) ([]k
 }
 
 // StreamKeysToWriter fetches keys from the database in batches and writes them to the provided writer.
-func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, writer io.Writer) error {
+func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, label string, writer io.Writer) error {
 	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Select("id, key_value")
 
-	switch statusFilter {
-	case models.KeyStatusActive, models.KeyStatusInvalid:
+	if label != "" {
+		query = whereKeyHasLabel(query, label)
+	}
+
+	switch {
+	case statusFilter == "all":
+	case slices.Contains(models.KeyStatuses, statusFilter):
 		query = query.Where("status = ?", statusFilter)
-	case "all":
 	default:
 		return fmt.Errorf("invalid status filter: %s", statusFilter)
 	}
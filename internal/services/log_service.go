@@ -48,6 +48,15 @@ func (s *LogService) logFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB
 			keyHash := s.EncryptionSvc.Hash(keyValue)
 			db = db.Where("key_hash = ?", keyHash)
 		}
+		if proxyKeyIDStr := c.Query("proxy_key_id"); proxyKeyIDStr != "" {
+			if proxyKeyID, err := strconv.ParseUint(proxyKeyIDStr, 10, 64); err == nil {
+				db = db.Where("proxy_key_id = ?", proxyKeyID)
+			}
+		}
+		if proxyKeyName := c.Query("proxy_key_name"); proxyKeyName != "" {
+			db = db.Where("proxy_key_id IN (?)",
+				s.DB.Model(&models.ProxyKey{}).Select("id").Where("name LIKE ?", "%"+proxyKeyName+"%"))
+		}
 		if model := c.Query("model"); model != "" {
 			db = db.Where("model LIKE ?", "%"+model+"%")
 		}
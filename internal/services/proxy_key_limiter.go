@@ -0,0 +1,309 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RateLimitRequests, RateLimitTokens and RateLimitConcurrency name the limit a request exceeded.
+	RateLimitRequests    = "requests"
+	RateLimitTokens      = "tokens"
+	RateLimitConcurrency = "concurrency"
+
+	// inflightLeaseTTL bounds how long a lease outlives its last renewal, so that leases leaked by
+	// a crashed node stop blocking the key. Running requests renew their lease every inflightLeaseRenewInterval.
+	inflightLeaseTTL           = 2 * time.Minute
+	inflightLeaseRenewInterval = 30 * time.Second
+
+	// quotaLookupsPerMinute bounds how often one client address may query proxy key quotas,
+	// which would otherwise let the endpoint be used to probe for valid keys.
+	quotaLookupsPerMinute = 30
+)
+
+// RateLimitError describes the limit a request exceeded and when it may be retried.
+type RateLimitError struct {
+	Limit      string
+	Max        int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	switch e.Limit {
+	case RateLimitConcurrency:
+		return fmt.Sprintf("Rate limit reached: at most %d concurrent requests are allowed for this key", e.Max)
+	default:
+		return fmt.Sprintf("Rate limit reached: at most %d %s per minute are allowed for this key. Please try again in %ds", e.Max, e.Limit, int(e.RetryAfter.Seconds()))
+	}
+}
+
+// ProxyKeyLimiter enforces the per-proxy-key request, token and concurrency limits.
+// Counters live in the shared store so the limits hold across master and slave nodes.
+type ProxyKeyLimiter struct {
+	store store.Store
+}
+
+// NewProxyKeyLimiter creates a new ProxyKeyLimiter.
+func NewProxyKeyLimiter(store store.Store) *ProxyKeyLimiter {
+	return &ProxyKeyLimiter{store: store}
+}
+
+// Acquire checks the limits of a key before a request and counts the request against them.
+// The returned release function must be called once the request has finished.
+// Store failures are logged and let the request through.
+func (l *ProxyKeyLimiter) Acquire(key *models.ProxyKey) (func(), *RateLimitError) {
+	now := time.Now()
+	release := func() {}
+
+	if key.TPMLimit > 0 {
+		window := l.window(key.ID, RateLimitTokens)
+		prev, curr, err := window.counts(now)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to read proxy key token window")
+		} else if window.estimate(now, prev, curr) >= float64(key.TPMLimit) {
+			return release, &RateLimitError{
+				Limit:      RateLimitTokens,
+				Max:        key.TPMLimit,
+				RetryAfter: window.retryAfter(now, prev, curr, int64(key.TPMLimit)),
+			}
+		}
+	}
+
+	if key.ConcurrencyLimit > 0 {
+		inflightKey := fmt.Sprintf("proxy_key_limit:%d:inflight_leases", key.ID)
+		leaseID := uuid.NewString()
+		inflight, err := l.acquireLease(inflightKey, leaseID, now)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to count proxy key in-flight requests")
+		} else {
+			release = l.holdLease(inflightKey, leaseID)
+			if inflight > int64(key.ConcurrencyLimit) {
+				release()
+				return func() {}, &RateLimitError{
+					Limit:      RateLimitConcurrency,
+					Max:        key.ConcurrencyLimit,
+					RetryAfter: time.Second,
+				}
+			}
+		}
+	}
+
+	if key.RPMLimit > 0 {
+		window := l.window(key.ID, RateLimitRequests)
+		if err := window.add(now, 1); err != nil {
+			logrus.WithError(err).Warn("Failed to count proxy key request")
+			return release, nil
+		}
+		prev, curr, err := window.counts(now)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to read proxy key request window")
+		} else if window.estimate(now, prev, curr) > float64(key.RPMLimit) {
+			if err := window.add(now, -1); err != nil {
+				logrus.WithError(err).Warn("Failed to roll back proxy key request")
+			}
+			release()
+			return func() {}, &RateLimitError{
+				Limit:      RateLimitRequests,
+				Max:        key.RPMLimit,
+				RetryAfter: window.retryAfter(now, prev, curr-1, int64(key.RPMLimit)),
+			}
+		}
+	}
+
+	return release, nil
+}
+
+// acquireLease records a lease for one in-flight request and returns the number of live leases,
+// including the new one. Each lease expires on its own, and expired leases are dropped here.
+func (l *ProxyKeyLimiter) acquireLease(inflightKey, leaseID string, now time.Time) (int64, error) {
+	if err := l.store.HSet(inflightKey, map[string]any{leaseID: now.Add(inflightLeaseTTL).UnixMilli()}); err != nil {
+		return 0, err
+	}
+	leases, err := l.store.HGetAll(inflightKey)
+	if err != nil {
+		return 0, err
+	}
+
+	var live int64
+	var expired []string
+	for id, value := range leases {
+		expiresAt, _ := strconv.ParseInt(value, 10, 64)
+		if expiresAt <= now.UnixMilli() {
+			expired = append(expired, id)
+			continue
+		}
+		live++
+	}
+	if len(expired) > 0 {
+		if err := l.store.HDel(inflightKey, expired...); err != nil {
+			logrus.WithError(err).Warn("Failed to drop expired proxy key in-flight leases")
+		}
+	}
+	return live, nil
+}
+
+// holdLease renews a lease until the returned release function is called, so that long streams and
+// Realtime sessions keep counting against the concurrency limit. Release is safe to call more than once.
+func (l *ProxyKeyLimiter) holdLease(inflightKey, leaseID string) func() {
+	var mu sync.Mutex
+	released := false
+	stop := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(inflightLeaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				mu.Lock()
+				if !released {
+					if err := l.store.HSet(inflightKey, map[string]any{leaseID: now.Add(inflightLeaseTTL).UnixMilli()}); err != nil {
+						logrus.WithError(err).Warn("Failed to renew proxy key in-flight lease")
+					}
+				}
+				mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if released {
+			return
+		}
+		released = true
+		close(stop)
+		if err := l.store.HDel(inflightKey, leaseID); err != nil {
+			logrus.WithError(err).Warn("Failed to release proxy key in-flight request")
+		}
+	}
+}
+
+// AllowQuotaLookup counts a quota lookup from the client address. When the client is over the limit it
+// returns false and how long to wait. Store failures are logged and let the lookup through.
+func (l *ProxyKeyLimiter) AllowQuotaLookup(clientIP string) (time.Duration, bool) {
+	now := time.Now()
+	window := slidingWindow{
+		store:  l.store,
+		prefix: "proxy_key_quota_lookup:" + clientIP,
+		size:   time.Minute,
+	}
+	if err := window.add(now, 1); err != nil {
+		logrus.WithError(err).Warn("Failed to count proxy key quota lookup")
+		return 0, true
+	}
+	prev, curr, err := window.counts(now)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to read proxy key quota lookup window")
+		return 0, true
+	}
+	if window.estimate(now, prev, curr) > quotaLookupsPerMinute {
+		return window.retryAfter(now, prev, curr-1, quotaLookupsPerMinute), false
+	}
+	return 0, true
+}
+
+// RecordTokens adds the tokens used by a finished request to the key's token window.
+func (l *ProxyKeyLimiter) RecordTokens(key *models.ProxyKey, tokens int64) {
+	if key.TPMLimit <= 0 || tokens <= 0 {
+		return
+	}
+	if err := l.window(key.ID, RateLimitTokens).add(time.Now(), tokens); err != nil {
+		logrus.WithError(err).Warn("Failed to record proxy key tokens")
+	}
+}
+
+func (l *ProxyKeyLimiter) window(keyID uint, limit string) slidingWindow {
+	return slidingWindow{
+		store:  l.store,
+		prefix: fmt.Sprintf("proxy_key_limit:%d:%s", keyID, limit),
+		size:   time.Minute,
+	}
+}
+
+// slidingWindow approximates a sliding window by weighting the previous fixed window
+// with the part of it that still overlaps the sliding one.
+type slidingWindow struct {
+	store  store.Store
+	prefix string
+	size   time.Duration
+}
+
+// slotKey maps a window to one of four rotating keys. A slot expires two windows after its
+// last write, so it is always empty again by the time it is reused.
+func (w slidingWindow) slotKey(index int64) string {
+	return w.prefix + ":" + strconv.FormatInt(index%4, 10)
+}
+
+func (w slidingWindow) index(now time.Time) int64 {
+	return now.UnixNano() / int64(w.size)
+}
+
+func (w slidingWindow) add(now time.Time, n int64) error {
+	key := w.slotKey(w.index(now))
+	if _, err := w.store.IncrBy(key, n); err != nil {
+		return err
+	}
+	return w.store.Expire(key, 2*w.size)
+}
+
+func (w slidingWindow) counts(now time.Time) (prev, curr int64, err error) {
+	index := w.index(now)
+	if prev, err = w.read(w.slotKey(index - 1)); err != nil {
+		return 0, 0, err
+	}
+	if curr, err = w.read(w.slotKey(index)); err != nil {
+		return 0, 0, err
+	}
+	return prev, curr, nil
+}
+
+func (w slidingWindow) read(key string) (int64, error) {
+	value, err := w.store.Get(key)
+	if err == store.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// elapsed returns the fraction of the current fixed window that has passed.
+func (w slidingWindow) elapsed(now time.Time) float64 {
+	return float64(now.UnixNano()%int64(w.size)) / float64(w.size)
+}
+
+func (w slidingWindow) estimate(now time.Time, prev, curr int64) float64 {
+	return float64(prev)*(1-w.elapsed(now)) + float64(curr)
+}
+
+// retryAfter returns how long until the window has room for one more unit.
+func (w slidingWindow) retryAfter(now time.Time, prev, curr, limit int64) time.Duration {
+	elapsed := w.elapsed(now)
+	room := float64(limit - 1 - curr)
+
+	var wait float64
+	switch {
+	case room < 0:
+		// The current window alone is full: wait until it becomes the previous window and decays enough
+		wait = 1 - elapsed + max(0, 1-float64(limit-1)/float64(curr))
+	case prev > 0:
+		wait = 1 - room/float64(prev) - elapsed
+	}
+
+	seconds := math.Ceil(wait * w.size.Seconds())
+	return time.Duration(max(seconds, 1)) * time.Second
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Quota periods of a proxy key. An empty period never resets, like QuotaPeriodTotal.
+const (
+	QuotaPeriodDaily   = "daily"
+	QuotaPeriodWeekly  = "weekly"
+	QuotaPeriodMonthly = "monthly"
+	QuotaPeriodTotal   = "total"
+
+	// quotaUsageCacheTTL bounds how stale the usage behind a quota check may be between log flushes.
+	quotaUsageCacheTTL = 30 * time.Second
+)
+
+// QuotaStatus reports the usage of a proxy key in its current quota period.
+// Remaining values are nil when the corresponding quota is unlimited.
+type QuotaStatus struct {
+	Period          string     `json:"period"`
+	PeriodStart     *time.Time `json:"period_start,omitempty"`
+	ResetsAt        *time.Time `json:"resets_at,omitempty"`
+	TokensUsed      int64      `json:"tokens_used"`
+	TokenQuota      int64      `json:"token_quota"`
+	TokensRemaining *int64     `json:"tokens_remaining,omitempty"`
+	SpendUsed       float64    `json:"spend_used"`
+	SpendQuota      float64    `json:"spend_quota"`
+	SpendRemaining  *float64   `json:"spend_remaining,omitempty"`
+}
+
+// Exhausted reports which quota has been used up, or "" if none.
+func (q *QuotaStatus) Exhausted() string {
+	if q.TokensRemaining != nil && *q.TokensRemaining <= 0 {
+		return "token"
+	}
+	if q.SpendRemaining != nil && *q.SpendRemaining <= 0 {
+		return "spend"
+	}
+	return ""
+}
+
+// QuotaExceededError is returned when a proxy key has used up its quota for the period.
+type QuotaExceededError struct {
+	Quota    string
+	ResetsAt *time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	if e.ResetsAt == nil {
+		return fmt.Sprintf("You exceeded the %s quota of this key.", e.Quota)
+	}
+	return fmt.Sprintf("You exceeded the %s quota of this key. It resets at %s.", e.Quota, e.ResetsAt.Format(time.RFC3339))
+}
+
+// HasQuota reports whether the key has a token or spend quota.
+func HasQuota(key *models.ProxyKey) bool {
+	return key.TokenQuota > 0 || key.SpendQuota > 0
+}
+
+// quotaPeriodBounds returns the start of the period containing now and when it resets.
+// Periods that never reset start at the zero time and have a zero reset time.
+func quotaPeriodBounds(period string, now time.Time) (time.Time, time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case QuotaPeriodDaily:
+		return day, day.AddDate(0, 0, 1)
+	case QuotaPeriodWeekly:
+		// Weeks start on Monday
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case QuotaPeriodMonthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return time.Time{}, time.Time{}
+	}
+}
+
+func isValidQuotaPeriod(period string) bool {
+	switch period {
+	case "", QuotaPeriodDaily, QuotaPeriodWeekly, QuotaPeriodMonthly, QuotaPeriodTotal:
+		return true
+	}
+	return false
+}
+
+// GetQuotaStatus returns the usage of the key in its current quota period.
+func (s *ProxyKeyService) GetQuotaStatus(key *models.ProxyKey) (*QuotaStatus, error) {
+	start, end := quotaPeriodBounds(key.QuotaPeriod, time.Now())
+	tokens, cost, err := s.periodUsage(key.ID, start)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &QuotaStatus{
+		Period:     key.QuotaPeriod,
+		TokensUsed: tokens,
+		TokenQuota: key.TokenQuota,
+		SpendUsed:  cost,
+		SpendQuota: key.SpendQuota,
+	}
+	if status.Period == "" {
+		status.Period = QuotaPeriodTotal
+	}
+	if !end.IsZero() {
+		status.PeriodStart = &start
+		status.ResetsAt = &end
+	}
+	if key.TokenQuota > 0 {
+		remaining := max(key.TokenQuota-tokens, 0)
+		status.TokensRemaining = &remaining
+	}
+	if key.SpendQuota > 0 {
+		remaining := max(key.SpendQuota-cost, 0)
+		status.SpendRemaining = &remaining
+	}
+	return status, nil
+}
+
+// CheckQuota rejects a request of a key whose quota is used up. Usage lags behind by up to the
+// request log write interval, as counters only grow from flushed logs.
+// Failing to read the usage lets the request through.
+func (s *ProxyKeyService) CheckQuota(key *models.ProxyKey) *QuotaExceededError {
+	if !HasQuota(key) {
+		return nil
+	}
+	status, err := s.GetQuotaStatus(key)
+	if err != nil {
+		logrus.WithError(err).WithField("proxy_key_id", key.ID).Warn("Failed to check proxy key quota")
+		return nil
+	}
+	if quota := status.Exhausted(); quota != "" {
+		return &QuotaExceededError{Quota: quota, ResetsAt: status.ResetsAt}
+	}
+	return nil
+}
+
+type cachedQuotaUsage struct {
+	Start  int64   `json:"start"`
+	Tokens int64   `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+func proxyKeyQuotaCacheKey(keyID uint) string {
+	return fmt.Sprintf("proxy_key_quota:%d", keyID)
+}
+
+// periodUsage sums the daily usage of a key since start, caching the result briefly.
+func (s *ProxyKeyService) periodUsage(keyID uint, start time.Time) (int64, float64, error) {
+	cacheKey := proxyKeyQuotaCacheKey(keyID)
+	if raw, err := s.store.Get(cacheKey); err == nil {
+		var cached cachedQuotaUsage
+		if json.Unmarshal(raw, &cached) == nil && cached.Start == start.Unix() {
+			return cached.Tokens, cached.Cost, nil
+		}
+	}
+
+	var usage struct {
+		Tokens int64
+		Cost   float64
+	}
+	err := s.db.Model(&models.ProxyKeyDailyUsage{}).
+		Select("COALESCE(SUM(tokens), 0) as tokens, COALESCE(SUM(cost), 0) as cost").
+		Where("proxy_key_id = ? AND date >= ?", keyID, start).
+		Scan(&usage).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if raw, err := json.Marshal(cachedQuotaUsage{Start: start.Unix(), Tokens: usage.Tokens, Cost: usage.Cost}); err == nil {
+		if err := s.store.Set(cacheKey, raw, quotaUsageCacheTTL); err != nil {
+			logrus.WithError(err).Debug("Failed to cache proxy key quota usage")
+		}
+	}
+	return usage.Tokens, usage.Cost, nil
+}
@@ -0,0 +1,523 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"gpt-load/internal/config"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/syncer"
+	"gpt-load/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const ProxyKeyUpdateChannel = "proxy_keys:updated"
+
+// ProxyKeyContextKey holds the *models.ProxyKey that authenticated the request.
+// It is absent when the request was authorized by a legacy proxy key string.
+const ProxyKeyContextKey = "proxy_key_record"
+
+// ProxyKeyParams defines the fields of a proxy key that can be created or updated.
+type ProxyKeyParams struct {
+	Name      *string
+	Key       *string
+	Enabled   *bool
+	AllGroups *bool
+	GroupIDs  []uint
+	ExpiresAt *time.Time
+	// Limits of 0 are unlimited.
+	RPMLimit         *int
+	TPMLimit         *int
+	ConcurrencyLimit *int
+	// Priority is the class of queued requests: high, normal or low.
+	Priority *string
+	// Quotas of 0 are unlimited.
+	QuotaPeriod *string
+	TokenQuota  *int64
+	SpendQuota  *float64
+	// Request restrictions. An empty model list allows every model.
+	AllowedModels   *string
+	MaxOutputTokens *int
+	DenyTools       *bool
+	DenyVision      *bool
+	// ClearExpiry removes the expiry of an existing key.
+	ClearExpiry bool
+}
+
+// ProxyKeyService manages proxy keys and caches them by the hash of their secret.
+type ProxyKeyService struct {
+	syncer          *syncer.CacheSyncer[map[string]*models.ProxyKey]
+	db              *gorm.DB
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
+}
+
+// NewProxyKeyService creates a new, uninitialized ProxyKeyService.
+func NewProxyKeyService(db *gorm.DB, store store.Store, settingsManager *config.SystemSettingsManager) *ProxyKeyService {
+	return &ProxyKeyService{
+		db:              db,
+		store:           store,
+		settingsManager: settingsManager,
+	}
+}
+
+// Initialize sets up the CacheSyncer.
+func (s *ProxyKeyService) Initialize() error {
+	loader := func() (map[string]*models.ProxyKey, error) {
+		var keys []*models.ProxyKey
+		if err := s.db.Find(&keys).Error; err != nil {
+			return nil, fmt.Errorf("failed to load proxy keys from db: %w", err)
+		}
+
+		keyMap := make(map[string]*models.ProxyKey, len(keys))
+		for _, key := range keys {
+			keyMap[key.KeyHash] = key
+		}
+		return keyMap, nil
+	}
+
+	syncer, err := syncer.NewCacheSyncer(
+		loader,
+		s.store,
+		ProxyKeyUpdateChannel,
+		logrus.WithField("syncer", "proxy_keys"),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create proxy key syncer: %w", err)
+	}
+	s.syncer = syncer
+	return nil
+}
+
+// Stop gracefully stops the background syncer.
+func (s *ProxyKeyService) Stop(ctx context.Context) {
+	if s.syncer != nil {
+		s.syncer.Stop()
+	}
+}
+
+// Authorize checks whether the key may access the group. Once the proxy_keys table has keys it is the
+// only source of proxy keys, so that deleting a migrated key revokes it. The legacy proxy key strings
+// are only accepted while the table is empty.
+// The returned record is nil when the key was authorized by a legacy string.
+func (s *ProxyKeyService) Authorize(key string, group *models.Group) (*models.ProxyKey, bool) {
+	if s.syncer != nil {
+		keys := s.syncer.Get()
+		if record, ok := keys[hashProxyKey(key)]; ok {
+			allowed := record.Enabled && !record.IsExpired(time.Now()) && record.AllowsGroup(group.ID)
+			return record, allowed
+		}
+		if len(keys) > 0 {
+			return nil, false
+		}
+	}
+
+	// Check both key collections to prevent timing attacks
+	_, existsInEffective := group.EffectiveConfig.ProxyKeysMap[key]
+	_, existsInGroup := group.ProxyKeysMap[key]
+	return nil, existsInEffective || existsInGroup
+}
+
+// Lookup returns the proxy key record with the given secret.
+func (s *ProxyKeyService) Lookup(key string) (*models.ProxyKey, bool) {
+	if s.syncer == nil {
+		return nil, false
+	}
+	record, ok := s.syncer.Get()[hashProxyKey(key)]
+	return record, ok
+}
+
+// ListProxyKeys returns all proxy keys.
+func (s *ProxyKeyService) ListProxyKeys(ctx context.Context) ([]models.ProxyKey, error) {
+	var keys []models.ProxyKey
+	if err := s.db.WithContext(ctx).Order("id asc").Find(&keys).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return keys, nil
+}
+
+// CreateProxyKey creates a proxy key and returns it with its secret, which is not stored and cannot be shown again.
+// A secret is generated when params.Key is empty.
+func (s *ProxyKeyService) CreateProxyKey(ctx context.Context, params ProxyKeyParams) (*models.ProxyKey, string, error) {
+	name := ""
+	if params.Name != nil {
+		name = strings.TrimSpace(*params.Name)
+	}
+	if name == "" {
+		return nil, "", NewI18nError(app_errors.ErrValidation, "validation.proxy_key_name_required", nil)
+	}
+
+	secret := ""
+	if params.Key != nil {
+		secret = strings.TrimSpace(*params.Key)
+	}
+	if secret == "" {
+		generated, err := generateProxyKey()
+		if err != nil {
+			return nil, "", app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to generate proxy key")
+		}
+		secret = generated
+	}
+
+	key := &models.ProxyKey{
+		Name:      name,
+		KeyHash:   hashProxyKey(secret),
+		KeyHint:   proxyKeyHint(secret),
+		Enabled:   true,
+		ExpiresAt: params.ExpiresAt,
+	}
+	if params.Enabled != nil {
+		key.Enabled = *params.Enabled
+	}
+	if params.AllGroups != nil {
+		key.AllGroups = *params.AllGroups
+	}
+	groupIDs, err := s.validateGroupIDs(ctx, params.GroupIDs)
+	if err != nil {
+		return nil, "", err
+	}
+	key.GroupIDs = groupIDs
+	if err := applyProxyKeyLimits(key, params); err != nil {
+		return nil, "", err
+	}
+
+	if err := s.db.WithContext(ctx).Create(key).Error; err != nil {
+		return nil, "", app_errors.ParseDBError(err)
+	}
+
+	s.invalidate(ctx)
+	return key, secret, nil
+}
+
+// UpdateProxyKey updates the fields set in params. A new secret replaces the old one when params.Key is set.
+func (s *ProxyKeyService) UpdateProxyKey(ctx context.Context, id uint, params ProxyKeyParams) (*models.ProxyKey, error) {
+	var key models.ProxyKey
+	if err := s.db.WithContext(ctx).First(&key, id).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	if params.Name != nil {
+		name := strings.TrimSpace(*params.Name)
+		if name == "" {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.proxy_key_name_required", nil)
+		}
+		key.Name = name
+	}
+	if params.Key != nil {
+		if secret := strings.TrimSpace(*params.Key); secret != "" {
+			key.KeyHash = hashProxyKey(secret)
+			key.KeyHint = proxyKeyHint(secret)
+		}
+	}
+	if params.Enabled != nil {
+		key.Enabled = *params.Enabled
+	}
+	if params.AllGroups != nil {
+		key.AllGroups = *params.AllGroups
+	}
+	if params.GroupIDs != nil {
+		groupIDs, err := s.validateGroupIDs(ctx, params.GroupIDs)
+		if err != nil {
+			return nil, err
+		}
+		key.GroupIDs = groupIDs
+	}
+	if params.ClearExpiry {
+		key.ExpiresAt = nil
+	} else if params.ExpiresAt != nil {
+		key.ExpiresAt = params.ExpiresAt
+	}
+	if err := applyProxyKeyLimits(&key, params); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(&key).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	s.invalidate(ctx)
+	return &key, nil
+}
+
+// DeleteProxyKey removes a proxy key.
+func (s *ProxyKeyService) DeleteProxyKey(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Delete(&models.ProxyKey{}, id)
+	if result.Error != nil {
+		return app_errors.ParseDBError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return app_errors.ErrResourceNotFound
+	}
+
+	s.invalidate(ctx)
+	return nil
+}
+
+// MigrateLegacyKeys imports the comma-separated proxy keys of the system settings and of every group
+// into the proxy_keys table. It only runs while the table is empty, so deleted keys are not re-imported.
+func (s *ProxyKeyService) MigrateLegacyKeys() error {
+	var count int64
+	if err := s.db.Model(&models.ProxyKey{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count proxy keys: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var groups []models.Group
+	if err := s.db.Select("id", "name", "proxy_keys").Order("id asc").Find(&groups).Error; err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	var keys []*models.ProxyKey
+	byHash := make(map[string]*models.ProxyKey)
+	add := func(secret, name string, groupID uint) {
+		hash := hashProxyKey(secret)
+		if existing, ok := byHash[hash]; ok {
+			if !existing.AllGroups && groupID != 0 && !existing.AllowsGroup(groupID) {
+				existing.GroupIDs = append(existing.GroupIDs, groupID)
+			}
+			return
+		}
+		key := &models.ProxyKey{
+			Name:      name,
+			KeyHash:   hash,
+			KeyHint:   proxyKeyHint(secret),
+			Enabled:   true,
+			AllGroups: groupID == 0,
+			GroupIDs:  datatypes.JSONSlice[uint]{},
+		}
+		if groupID != 0 {
+			key.GroupIDs = append(key.GroupIDs, groupID)
+		}
+		byHash[hash] = key
+		keys = append(keys, key)
+	}
+
+	for i, secret := range utils.SplitAndTrim(s.settingsManager.GetSettings().ProxyKeys, ",") {
+		add(secret, fmt.Sprintf("global-%d", i+1), 0)
+	}
+	for _, group := range groups {
+		for i, secret := range utils.SplitAndTrim(group.ProxyKeys, ",") {
+			add(secret, fmt.Sprintf("%s-%d", group.Name, i+1), group.ID)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := s.db.CreateInBatches(keys, 100).Error; err != nil {
+		return fmt.Errorf("failed to import legacy proxy keys: %w", err)
+	}
+	logrus.Infof("Imported %d legacy proxy keys into the proxy_keys table", len(keys))
+	return nil
+}
+
+// ImportLegacyKeys imports the secrets that a save of the global or a group's comma-separated proxy keys added,
+// so that keys entered there keep working once the proxy_keys table is the only source of proxy keys.
+// groupID is 0 for the global keys. Nothing is imported while the table is empty, because the legacy strings
+// are still accepted then and importing one key would revoke the others.
+func (s *ProxyKeyService) ImportLegacyKeys(ctx context.Context, previous, current string, groupID uint, namePrefix string) error {
+	existing := make(map[string]struct{})
+	for _, secret := range utils.SplitAndTrim(previous, ",") {
+		existing[secret] = struct{}{}
+	}
+	added := make(map[string]int)
+	for i, secret := range utils.SplitAndTrim(current, ",") {
+		if _, ok := existing[secret]; !ok {
+			added[secret] = i
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.ProxyKey{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count proxy keys: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	imported := 0
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for secret, i := range added {
+			var matches []models.ProxyKey
+			if err := tx.Where("key_hash = ?", hashProxyKey(secret)).Limit(1).Find(&matches).Error; err != nil {
+				return err
+			}
+			if len(matches) == 0 {
+				key := models.ProxyKey{
+					Name:      fmt.Sprintf("%s-%d", namePrefix, i+1),
+					KeyHash:   hashProxyKey(secret),
+					KeyHint:   proxyKeyHint(secret),
+					Enabled:   true,
+					AllGroups: groupID == 0,
+					GroupIDs:  datatypes.JSONSlice[uint]{},
+				}
+				if groupID != 0 {
+					key.GroupIDs = append(key.GroupIDs, groupID)
+				}
+				if err := tx.Create(&key).Error; err != nil {
+					return err
+				}
+				imported++
+				continue
+			}
+
+			// The secret is already a proxy key: grant it the scope it was entered with
+			key := &matches[0]
+			switch {
+			case key.AllGroups:
+				continue
+			case groupID == 0:
+				key.AllGroups = true
+			case !key.AllowsGroup(groupID):
+				key.GroupIDs = append(key.GroupIDs, groupID)
+			default:
+				continue
+			}
+			if err := tx.Save(key).Error; err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import legacy proxy keys: %w", err)
+	}
+
+	if imported > 0 {
+		logrus.WithContext(ctx).Infof("Imported %d proxy keys from %s", imported, namePrefix)
+		s.invalidate(ctx)
+	}
+	return nil
+}
+
+// applyProxyKeyLimits copies the limits, quotas and request restrictions set in params onto the key,
+// rejecting invalid values.
+func applyProxyKeyLimits(key *models.ProxyKey, params ProxyKeyParams) error {
+	limits := []struct {
+		value  *int
+		target *int
+	}{
+		{params.RPMLimit, &key.RPMLimit},
+		{params.TPMLimit, &key.TPMLimit},
+		{params.ConcurrencyLimit, &key.ConcurrencyLimit},
+		{params.MaxOutputTokens, &key.MaxOutputTokens},
+	}
+	for _, limit := range limits {
+		if limit.value == nil {
+			continue
+		}
+		if *limit.value < 0 {
+			return NewI18nError(app_errors.ErrValidation, "validation.proxy_key_limit_negative", nil)
+		}
+		*limit.target = *limit.value
+	}
+
+	if params.Priority != nil {
+		if !isValidPriority(*params.Priority) {
+			return NewI18nError(app_errors.ErrValidation, "validation.invalid_priority", nil)
+		}
+		key.Priority = *params.Priority
+	}
+
+	if params.QuotaPeriod != nil {
+		if !isValidQuotaPeriod(*params.QuotaPeriod) {
+			return NewI18nError(app_errors.ErrValidation, "validation.invalid_quota_period", nil)
+		}
+		key.QuotaPeriod = *params.QuotaPeriod
+	}
+	if (params.TokenQuota != nil && *params.TokenQuota < 0) || (params.SpendQuota != nil && *params.SpendQuota < 0) {
+		return NewI18nError(app_errors.ErrValidation, "validation.proxy_key_limit_negative", nil)
+	}
+	if params.TokenQuota != nil {
+		key.TokenQuota = *params.TokenQuota
+	}
+	if params.SpendQuota != nil {
+		key.SpendQuota = *params.SpendQuota
+	}
+
+	if params.AllowedModels != nil {
+		key.AllowedModels = strings.Join(utils.SplitRules(*params.AllowedModels), ",")
+	}
+	if params.DenyTools != nil {
+		key.DenyTools = *params.DenyTools
+	}
+	if params.DenyVision != nil {
+		key.DenyVision = *params.DenyVision
+	}
+	return nil
+}
+
+// validateGroupIDs deduplicates the group IDs and checks that every group exists.
+func (s *ProxyKeyService) validateGroupIDs(ctx context.Context, groupIDs []uint) (datatypes.JSONSlice[uint], error) {
+	unique := make(datatypes.JSONSlice[uint], 0, len(groupIDs))
+	seen := make(map[uint]struct{}, len(groupIDs))
+	for _, id := range groupIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Group{}).Where("id IN ?", []uint(unique)).Count(&count).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if int(count) != len(unique) {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.group_not_found", nil)
+	}
+	return unique, nil
+}
+
+func (s *ProxyKeyService) invalidate(ctx context.Context) {
+	if s.syncer == nil {
+		return
+	}
+	if err := s.syncer.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate proxy key cache")
+	}
+}
+
+// hashProxyKey hashes a proxy key secret with plain SHA-256, so the stored hashes
+// stay valid when the encryption key is rotated.
+func hashProxyKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// proxyKeyHint masks a secret for display. Unlike MaskAPIKey it never reveals a short secret in full.
+func proxyKeyHint(secret string) string {
+	if len(secret) <= 8 {
+		return secret[:min(2, len(secret))] + "****"
+	}
+	return utils.MaskAPIKey(secret)
+}
+
+// generateProxyKey returns a new random proxy key secret.
+func generateProxyKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
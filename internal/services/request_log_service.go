@@ -202,7 +202,7 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 		return nil
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.CreateInBatches(logs, len(logs)).Error; err != nil {
 			return fmt.Errorf("failed to batch insert request logs: %w", err)
 		}
@@ -294,6 +294,128 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 			}
 		}
 
-		return nil
+		if err := upsertGroupModelHourlyStats(tx, logs); err != nil {
+			return err
+		}
+		return upsertProxyKeyDailyUsages(tx, logs)
 	})
+	if err != nil {
+		return err
+	}
+
+	// 丢弃配额用量缓存，使下一次配额检查读取到最新用量
+	for _, log := range logs {
+		if log.ProxyKeyID == 0 {
+			continue
+		}
+		if err := s.store.Delete(proxyKeyQuotaCacheKey(log.ProxyKeyID)); err != nil {
+			logrus.WithError(err).Debug("Failed to drop proxy key quota cache")
+		}
+	}
+	return nil
+}
+
+type proxyKeyDayKey struct {
+	ProxyKeyID uint
+	Date       time.Time
+}
+
+// upsertProxyKeyDailyUsages 按代理密钥和天汇总请求数、Token 与费用，供配额检查使用
+func upsertProxyKeyDailyUsages(tx *gorm.DB, logs []*models.RequestLog) error {
+	usages := make(map[proxyKeyDayKey]*models.ProxyKeyDailyUsage)
+	for _, log := range logs {
+		if log.ProxyKeyID == 0 {
+			continue
+		}
+		ts := log.Timestamp.Local()
+		key := proxyKeyDayKey{
+			ProxyKeyID: log.ProxyKeyID,
+			Date:       time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, ts.Location()),
+		}
+		usage, ok := usages[key]
+		if !ok {
+			usage = &models.ProxyKeyDailyUsage{ProxyKeyID: key.ProxyKeyID, Date: key.Date}
+			usages[key] = usage
+		}
+		if log.RequestType != models.RequestTypeRetry {
+			usage.RequestCount++
+		}
+		usage.Tokens += log.PromptTokens + log.CompletionTokens
+		usage.Cost += log.Cost
+	}
+
+	for _, usage := range usages {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "proxy_key_id"}, {Name: "date"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"request_count": gorm.Expr("proxy_key_daily_usages.request_count + ?", usage.RequestCount),
+				"tokens":        gorm.Expr("proxy_key_daily_usages.tokens + ?", usage.Tokens),
+				"cost":          gorm.Expr("proxy_key_daily_usages.cost + ?", usage.Cost),
+				"updated_at":    time.Now(),
+			}),
+		}).Create(usage).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert proxy key daily usage: %w", err)
+		}
+	}
+	return nil
+}
+
+type groupModelHourKey struct {
+	Time    time.Time
+	GroupID uint
+	Model   string
+}
+
+// upsertGroupModelHourlyStats 按分组、模型和小时汇总 Token、费用与耗时，聚合分组同样记录一份
+func upsertGroupModelHourlyStats(tx *gorm.DB, logs []*models.RequestLog) error {
+	stats := make(map[groupModelHourKey]*models.GroupModelHourlyStat)
+	add := func(key groupModelHourKey, log *models.RequestLog) {
+		stat, ok := stats[key]
+		if !ok {
+			stat = &models.GroupModelHourlyStat{Time: key.Time, GroupID: key.GroupID, Model: key.Model}
+			stats[key] = stat
+		}
+		if log.IsSuccess {
+			stat.SuccessCount++
+		} else {
+			stat.FailureCount++
+		}
+		stat.PromptTokens += log.PromptTokens
+		stat.CompletionTokens += log.CompletionTokens
+		stat.Cost += log.Cost
+		stat.LatencySum += log.Duration
+		stat.LatencyCount++
+	}
+
+	for _, log := range logs {
+		if log.RequestType == models.RequestTypeRetry {
+			continue
+		}
+		hourlyTime := log.Timestamp.Truncate(time.Hour)
+		add(groupModelHourKey{Time: hourlyTime, GroupID: log.GroupID, Model: log.Model}, log)
+		if log.ParentGroupID > 0 {
+			add(groupModelHourKey{Time: hourlyTime, GroupID: log.ParentGroupID, Model: log.Model}, log)
+		}
+	}
+
+	for _, stat := range stats {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "time"}, {Name: "group_id"}, {Name: "model"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"success_count":     gorm.Expr("group_model_hourly_stats.success_count + ?", stat.SuccessCount),
+				"failure_count":     gorm.Expr("group_model_hourly_stats.failure_count + ?", stat.FailureCount),
+				"prompt_tokens":     gorm.Expr("group_model_hourly_stats.prompt_tokens + ?", stat.PromptTokens),
+				"completion_tokens": gorm.Expr("group_model_hourly_stats.completion_tokens + ?", stat.CompletionTokens),
+				"cost":              gorm.Expr("group_model_hourly_stats.cost + ?", stat.Cost),
+				"latency_sum":       gorm.Expr("group_model_hourly_stats.latency_sum + ?", stat.LatencySum),
+				"latency_count":     gorm.Expr("group_model_hourly_stats.latency_count + ?", stat.LatencyCount),
+				"updated_at":        time.Now(),
+			}),
+		}).Create(stat).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert group model hourly stat: %w", err)
+		}
+	}
+	return nil
 }
@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"math/rand"
 	"strconv"
 	"sync"
 	"time"
@@ -146,6 +147,53 @@ func (s *MemoryStore) SetNX(key string, value []byte, ttl time.Duration) (bool,
 	return true, nil
 }
 
+// IncrBy atomically adds incr to the integer value of a key, keeping its expiry.
+func (s *MemoryStore) IncrBy(key string, incr int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var item memoryStoreItem
+	if rawItem, exists := s.data[key]; exists {
+		var ok bool
+		item, ok = rawItem.(memoryStoreItem)
+		if !ok {
+			return 0, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+		}
+		if item.expiresAt > 0 && time.Now().UnixNano() > item.expiresAt {
+			item = memoryStoreItem{}
+		}
+	}
+
+	currentVal, _ := strconv.ParseInt(string(item.value), 10, 64)
+	newVal := currentVal + incr
+	item.value = []byte(strconv.FormatInt(newVal, 10))
+	s.data[key] = item
+
+	return newVal, nil
+}
+
+// Expire sets the TTL of an existing key. Only simple K/V items support expiry.
+func (s *MemoryStore) Expire(key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawItem, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+	item, ok := rawItem.(memoryStoreItem)
+	if !ok {
+		return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	item.expiresAt = 0
+	if ttl > 0 {
+		item.expiresAt = time.Now().UnixNano() + ttl.Nanoseconds()
+	}
+	s.data[key] = item
+	return nil
+}
+
 // --- HASH operations ---
 
 func (s *MemoryStore) HSet(key string, values map[string]any) error {
@@ -217,6 +265,29 @@ func (s *MemoryStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return newVal, nil
 }
 
+func (s *MemoryStore) HDel(key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawHash, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+
+	hash, ok := rawHash.(map[string]string)
+	if !ok {
+		return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	for _, field := range fields {
+		delete(hash, field)
+	}
+	if len(hash) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
 // --- LIST operations ---
 
 func (s *MemoryStore) LPush(key string, values ...any) error {
@@ -274,10 +345,44 @@ func (s *MemoryStore) LRem(key string, count int64, value any) error {
 	return nil
 }
 
+// LRemUnlessLast removes value from the list unless it is the last member.
+func (s *MemoryStore) LRemUnlessLast(key string, value any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawList, exists := s.data[key]
+	if !exists {
+		return false, nil
+	}
+
+	list, ok := rawList.([]string)
+	if !ok {
+		return false, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	if len(list) <= 1 {
+		return false, nil
+	}
+
+	strValue := fmt.Sprint(value)
+	newList := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != strValue {
+			newList = append(newList, item)
+		}
+	}
+	s.data[key] = newList
+	return len(newList) < len(list), nil
+}
+
 func (s *MemoryStore) Rotate(key string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.rotateLocked(key)
+}
+
+// rotateLocked moves the last list item to the head. The caller must hold s.mu.
+func (s *MemoryStore) rotateLocked(key string) (string, error) {
 	rawList, exists := s.data[key]
 	if !exists {
 		return "", ErrNotFound
@@ -302,6 +407,92 @@ func (s *MemoryStore) Rotate(key string) (string, error) {
 	return item, nil
 }
 
+// SelectWeighted picks a list member by smooth weighted round-robin over the highest priority tier.
+func (s *MemoryStore) SelectWeighted(listKey, weightsKey, stateKey string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	weights, _ := s.data[weightsKey].(map[string]string)
+	if len(weights) == 0 {
+		return s.rotateLocked(listKey)
+	}
+
+	list, err := s.nonEmptyListLocked(listKey)
+	if err != nil {
+		return "", err
+	}
+
+	current, _ := s.data[stateKey].(map[string]string)
+	selected, next := selectSmoothWeighted(list, weights, current)
+	s.data[stateKey] = next
+	return selected, nil
+}
+
+// SelectRandom picks a random list member of the highest priority tier, weighted by the weights hash.
+func (s *MemoryStore) SelectRandom(listKey, weightsKey string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.nonEmptyListLocked(listKey)
+	if err != nil {
+		return "", err
+	}
+	weights, _ := s.data[weightsKey].(map[string]string)
+	return selectRandomWeighted(list, weights, rand.Float64()), nil
+}
+
+// SelectLowest picks the list member of the highest priority tier with the lowest score in scoresKey.
+func (s *MemoryStore) SelectLowest(listKey, weightsKey, scoresKey string, score int64, incr bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.nonEmptyListLocked(listKey)
+	if err != nil {
+		return "", err
+	}
+	weights, _ := s.data[weightsKey].(map[string]string)
+	scores, ok := s.data[scoresKey].(map[string]string)
+	if !ok {
+		scores = make(map[string]string)
+	}
+
+	selected := selectLowestScore(list, weights, scores)
+	moved := make([]string, 0, len(list))
+	for _, member := range list {
+		if member != selected {
+			moved = append(moved, member)
+		}
+	}
+	s.data[listKey] = append(moved, selected)
+
+	if incr {
+		if score == 0 {
+			return selected, nil
+		}
+		current, _ := strconv.ParseInt(scores[selected], 10, 64)
+		score += current
+	}
+	scores[selected] = strconv.FormatInt(score, 10)
+	s.data[scoresKey] = scores
+	return selected, nil
+}
+
+// nonEmptyListLocked returns the list stored at key, or ErrNotFound if it is empty. The caller must hold s.mu.
+func (s *MemoryStore) nonEmptyListLocked(key string) ([]string, error) {
+	rawList, exists := s.data[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	list, ok := rawList.([]string)
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return list, nil
+}
+
 // LLen returns the length of a list.
 func (s *MemoryStore) LLen(key string) (int64, error) {
 	s.mu.RLock()
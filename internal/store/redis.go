@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -81,6 +82,16 @@ func (s *RedisStore) SetNX(key string, value []byte, ttl time.Duration) (bool, e
 	return s.client.SetNX(context.Background(), s.prefixKey(key), value, ttl).Result()
 }
 
+// IncrBy atomically increments the integer value of a key in Redis.
+func (s *RedisStore) IncrBy(key string, incr int64) (int64, error) {
+	return s.client.IncrBy(context.Background(), s.prefixKey(key), incr).Result()
+}
+
+// Expire sets the TTL of a key in Redis.
+func (s *RedisStore) Expire(key string, ttl time.Duration) error {
+	return s.client.Expire(context.Background(), s.prefixKey(key), ttl).Err()
+}
+
 // Close closes the Redis client connection.
 func (s *RedisStore) Close() error {
 	return s.client.Close()
@@ -100,6 +111,10 @@ func (s *RedisStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return s.client.HIncrBy(context.Background(), s.prefixKey(key), field, incr).Result()
 }
 
+func (s *RedisStore) HDel(key string, fields ...string) error {
+	return s.client.HDel(context.Background(), s.prefixKey(key), fields...).Err()
+}
+
 // --- LIST operations ---
 
 func (s *RedisStore) LPush(key string, values ...any) error {
@@ -110,6 +125,24 @@ func (s *RedisStore) LRem(key string, count int64, value any) error {
 	return s.client.LRem(context.Background(), s.prefixKey(key), count, value).Err()
 }
 
+// lremUnlessLastScript checks the list length and removes the member in one step, so that concurrent
+// callers cannot empty the list between the check and the removal.
+var lremUnlessLastScript = redis.NewScript(`
+if redis.call('LLEN', KEYS[1]) <= 1 then
+	return 0
+end
+return redis.call('LREM', KEYS[1], 0, ARGV[1])
+`)
+
+// LRemUnlessLast removes value from the list unless it is the last member.
+func (s *RedisStore) LRemUnlessLast(key string, value any) (bool, error) {
+	removed, err := lremUnlessLastScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, value).Int64()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
 func (s *RedisStore) Rotate(key string) (string, error) {
 	prefixedKey := s.prefixKey(key)
 	val, err := s.client.RPopLPush(context.Background(), prefixedKey, prefixedKey).Result()
@@ -122,6 +155,114 @@ func (s *RedisStore) Rotate(key string) (string, error) {
 	return val, nil
 }
 
+// selectWeightedScript mirrors selectSmoothWeighted so that the selection and the running weights are updated atomically.
+var selectWeightedScript = redis.NewScript(`
+if redis.call('HLEN', KEYS[2]) == 0 then
+	return redis.call('RPOPLPUSH', KEYS[1], KEYS[1])
+end
+` + luaTopPriorityTier + `
+local current = {}
+raw = redis.call('HGETALL', KEYS[3])
+for i = 1, #raw, 2 do
+	current[raw[i]] = tonumber(raw[i + 1]) or 0
+end
+
+local total, selected, selectedWeight = 0, nil, nil
+local running = {}
+for _, entry in ipairs(tier) do
+	local value = (current[entry[1]] or 0) + entry[2]
+	running[entry[1]] = value
+	total = total + entry[2]
+	if selected == nil or value > selectedWeight then
+		selected, selectedWeight = entry[1], value
+	end
+end
+running[selected] = running[selected] - total
+
+redis.call('DEL', KEYS[3])
+local args = {}
+for member, value in pairs(running) do
+	table.insert(args, member)
+	table.insert(args, tostring(value))
+end
+redis.call('HSET', KEYS[3], unpack(args))
+return selected
+`)
+
+// selectRandomScript mirrors selectRandomWeighted. The random number is passed in as ARGV[1], since
+// scripts may not call math.random non-deterministically on older Redis versions.
+var selectRandomScript = redis.NewScript(luaTopPriorityTier + `
+local total = 0
+for _, entry in ipairs(tier) do
+	total = total + entry[2]
+end
+local target = tonumber(ARGV[1]) * total
+local cumulative = 0
+for _, entry in ipairs(tier) do
+	cumulative = cumulative + entry[2]
+	if target < cumulative then
+		return entry[1]
+	end
+end
+return tier[#tier][1]
+`)
+
+// selectLowestScript mirrors selectLowestScore, then moves the selected member to the tail of the list and updates its score.
+// ARGV[1] is the score and ARGV[2] is "1" to increment the score by it, or "0" to set it.
+var selectLowestScript = redis.NewScript(luaTopPriorityTier + `
+local selected, lowest = nil, nil
+for _, entry in ipairs(tier) do
+	local score = tonumber(redis.call('HGET', KEYS[3], entry[1])) or 0
+	if selected == nil or score < lowest then
+		selected, lowest = entry[1], score
+	end
+end
+redis.call('LREM', KEYS[1], 1, selected)
+redis.call('RPUSH', KEYS[1], selected)
+
+if ARGV[2] == '1' then
+	if ARGV[1] ~= '0' then
+		redis.call('HINCRBY', KEYS[3], selected, ARGV[1])
+	end
+else
+	redis.call('HSET', KEYS[3], selected, ARGV[1])
+end
+return selected
+`)
+
+// SelectWeighted picks a list member by smooth weighted round-robin over the highest priority tier.
+func (s *RedisStore) SelectWeighted(listKey, weightsKey, stateKey string) (string, error) {
+	keys := s.prefixKeys([]string{listKey, weightsKey, stateKey})
+	return s.runSelectScript(selectWeightedScript, keys)
+}
+
+// SelectRandom picks a random list member of the highest priority tier, weighted by the weights hash.
+func (s *RedisStore) SelectRandom(listKey, weightsKey string) (string, error) {
+	keys := s.prefixKeys([]string{listKey, weightsKey})
+	return s.runSelectScript(selectRandomScript, keys, rand.Float64())
+}
+
+// SelectLowest picks the list member of the highest priority tier with the lowest score in scoresKey.
+func (s *RedisStore) SelectLowest(listKey, weightsKey, scoresKey string, score int64, incr bool) (string, error) {
+	keys := s.prefixKeys([]string{listKey, weightsKey, scoresKey})
+	incrArg := "0"
+	if incr {
+		incrArg = "1"
+	}
+	return s.runSelectScript(selectLowestScript, keys, score, incrArg)
+}
+
+func (s *RedisStore) runSelectScript(script *redis.Script, keys []string, args ...any) (string, error) {
+	val, err := script.Run(context.Background(), s.client, keys, args...).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return val, nil
+}
+
 // LLen returns the length of a list.
 func (s *RedisStore) LLen(key string) (int64, error) {
 	return s.client.LLen(context.Background(), s.prefixKey(key)).Result()
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FormatMemberWeight encodes the priority and weight of a list member for the weights hash of the Select operations.
+func FormatMemberWeight(priority, weight int) string {
+	return strconv.Itoa(priority) + ":" + strconv.Itoa(weight)
+}
+
+// parseMemberWeight decodes a weights hash value. Members without a valid value have priority 0 and weight 1.
+func parseMemberWeight(value string) (int64, int64) {
+	priorityStr, weightStr, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, 1
+	}
+	priority, err := strconv.ParseInt(priorityStr, 10, 64)
+	if err != nil {
+		priority = 0
+	}
+	weight, err := strconv.ParseInt(weightStr, 10, 64)
+	if err != nil || weight < 1 {
+		weight = 1
+	}
+	return priority, weight
+}
+
+// topPriorityTier returns the members of the highest priority, in list order, with their weights.
+func topPriorityTier(members []string, weights map[string]string) ([]string, map[string]int64) {
+	var tier []string
+	var topPriority int64
+	tierWeights := make(map[string]int64)
+	for _, member := range members {
+		priority, weight := parseMemberWeight(weights[member])
+		if len(tier) == 0 || priority > topPriority {
+			tier = tier[:0]
+			tierWeights = make(map[string]int64)
+			topPriority = priority
+		}
+		if priority == topPriority {
+			tier = append(tier, member)
+			tierWeights[member] = weight
+		}
+	}
+	return tier, tierWeights
+}
+
+// selectSmoothWeighted runs one round of smooth weighted round-robin over the highest priority members.
+// current holds the running weights and is replaced by the running weights of that tier.
+func selectSmoothWeighted(members []string, weights, current map[string]string) (string, map[string]string) {
+	tier, tierWeights := topPriorityTier(members, weights)
+	if len(tier) == 0 {
+		return "", current
+	}
+
+	var total int64
+	var selected string
+	var selectedWeight int64
+	running := make(map[string]int64, len(tier))
+	for _, member := range tier {
+		value, _ := strconv.ParseInt(current[member], 10, 64)
+		value += tierWeights[member]
+		running[member] = value
+		total += tierWeights[member]
+		if selected == "" || value > selectedWeight {
+			selected, selectedWeight = member, value
+		}
+	}
+	running[selected] -= total
+
+	next := make(map[string]string, len(running))
+	for member, value := range running {
+		next[member] = strconv.FormatInt(value, 10)
+	}
+	return selected, next
+}
+
+// selectRandomWeighted picks one of the highest priority members with a probability proportional to its weight.
+// r is a random number in [0, 1).
+func selectRandomWeighted(members []string, weights map[string]string, r float64) string {
+	tier, tierWeights := topPriorityTier(members, weights)
+	if len(tier) == 0 {
+		return ""
+	}
+
+	var total int64
+	for _, member := range tier {
+		total += tierWeights[member]
+	}
+	target := r * float64(total)
+	var cumulative int64
+	for _, member := range tier {
+		cumulative += tierWeights[member]
+		if target < float64(cumulative) {
+			return member
+		}
+	}
+	return tier[len(tier)-1]
+}
+
+// selectLowestScore picks the highest priority member with the lowest score, members without a score counting as 0.
+// Ties go to the member that comes first in the list.
+func selectLowestScore(members []string, weights, scores map[string]string) string {
+	tier, _ := topPriorityTier(members, weights)
+	var selected string
+	var lowest int64
+	for _, member := range tier {
+		score, _ := strconv.ParseInt(scores[member], 10, 64)
+		if selected == "" || score < lowest {
+			selected, lowest = member, score
+		}
+	}
+	return selected
+}
+
+// luaTopPriorityTier is the Lua counterpart of topPriorityTier, shared by the selection scripts.
+// It reads the list from KEYS[1] and the weights hash from KEYS[2], and defines members and tier.
+const luaTopPriorityTier = `
+local members = redis.call('LRANGE', KEYS[1], 0, -1)
+if #members == 0 then
+	return false
+end
+
+local weights = {}
+local raw = redis.call('HGETALL', KEYS[2])
+for i = 1, #raw, 2 do
+	weights[raw[i]] = raw[i + 1]
+end
+
+local tier = {}
+local topPriority = nil
+for _, member in ipairs(members) do
+	local priority, weight = 0, 1
+	local value = weights[member]
+	if value then
+		local sep = string.find(value, ':', 1, true)
+		if sep then
+			priority = tonumber(string.sub(value, 1, sep - 1)) or 0
+			weight = tonumber(string.sub(value, sep + 1)) or 1
+			if weight < 1 then
+				weight = 1
+			end
+		end
+	end
+	if topPriority == nil or priority > topPriority then
+		tier = {}
+		topPriority = priority
+	end
+	if priority == topPriority then
+		table.insert(tier, {member, weight})
+	end
+end
+`
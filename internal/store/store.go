@@ -40,15 +40,35 @@ type Store interface {
 	// SetNX sets a key-value pair if the key does not already exist.
 	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
 
+	// IncrBy atomically adds incr to the integer value of a key, starting from 0 if it does not exist.
+	IncrBy(key string, incr int64) (int64, error)
+
+	// Expire sets the TTL of an existing key.
+	Expire(key string, ttl time.Duration) error
+
 	// HASH operations
 	HSet(key string, values map[string]any) error
 	HGetAll(key string) (map[string]string, error)
 	HIncrBy(key, field string, incr int64) (int64, error)
+	HDel(key string, fields ...string) error
 
 	// LIST operations
 	LPush(key string, values ...any) error
 	LRem(key string, count int64, value any) error
+	// LRemUnlessLast removes all occurrences of value from the list unless the list holds at most one member,
+	// checking the length and removing atomically. It reports whether anything was removed.
+	LRemUnlessLast(key string, value any) (bool, error)
 	Rotate(key string) (string, error)
+	// SelectWeighted picks a member of the list by smooth weighted round-robin over the members of the
+	// highest priority, as configured in weightsKey. stateKey holds the running weights between calls.
+	// It rotates the list like Rotate when weightsKey is empty.
+	SelectWeighted(listKey, weightsKey, stateKey string) (string, error)
+	// SelectRandom picks a random member of the highest priority, weighted as configured in weightsKey.
+	SelectRandom(listKey, weightsKey string) (string, error)
+	// SelectLowest picks the member of the highest priority with the lowest score in the scoresKey hash, ties
+	// going to the member nearest the head, and moves it to the tail of the list. The selected member's score
+	// is then incremented by score when incr is true, or set to score otherwise.
+	SelectLowest(listKey, weightsKey, scoresKey string, score int64, incr bool) (string, error)
 	LLen(key string) (int64, error)
 
 	// SET operations
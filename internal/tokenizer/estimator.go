@@ -0,0 +1,186 @@
+// Package tokenizer provides local, approximate token counts for request payloads.
+package tokenizer
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+const (
+	// imageTokens approximates one image input, matching a high-detail 1024px image on OpenAI models.
+	imageTokens = 765
+	// messageOverhead covers the role and separator tokens added around every message.
+	messageOverhead = 4
+	// replyPriming covers the tokens that prime the assistant reply.
+	replyPriming = 3
+)
+
+// CountText estimates the tokens of a text the way a BPE tokenizer such as cl100k splits it:
+// roughly four characters per token for latin words, one token per CJK character or punctuation mark.
+func CountText(s string) int {
+	tokens := 0
+	wordLen := 0
+	flush := func() {
+		if wordLen > 0 {
+			tokens += (wordLen + 3) / 4
+			wordLen = 0
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			wordLen++
+		case unicode.IsSpace(r):
+			flush()
+		case isCJK(r):
+			flush()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			// Accented, Cyrillic and similar scripts split into shorter pieces
+			wordLen += 2
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// EstimateRequest estimates the input tokens of an OpenAI (Chat Completions, Completions, Responses),
+// Anthropic Messages or Gemini request body. It returns 0 when the body is not JSON.
+func EstimateRequest(body []byte) int {
+	var request map[string]any
+	if err := json.Unmarshal(body, &request); err != nil {
+		return 0
+	}
+
+	tokens := 0
+	// Conversation turns: OpenAI/Anthropic messages, Gemini contents, Responses input
+	for _, field := range []string{"messages", "contents", "input"} {
+		switch v := request[field].(type) {
+		case []any:
+			for _, message := range v {
+				tokens += EstimateMessage(message)
+			}
+			tokens += replyPriming
+		case string:
+			tokens += CountText(v) + replyPriming
+		}
+	}
+
+	// System prompts and legacy prompts
+	for _, field := range []string{"system", "systemInstruction", "system_instruction", "instructions", "prompt"} {
+		if v, ok := request[field]; ok {
+			tokens += countValue(v)
+		}
+	}
+
+	// Tool definitions are sent to the model as JSON schema text
+	for _, field := range []string{"tools", "functions"} {
+		if v, ok := request[field]; ok {
+			if raw, err := json.Marshal(v); err == nil {
+				tokens += CountText(string(raw))
+			}
+		}
+	}
+	return tokens
+}
+
+// countValue walks a JSON value and sums the tokens of its text, counting images as a fixed cost.
+func countValue(v any) int {
+	switch val := v.(type) {
+	case string:
+		return CountText(val)
+	case []any:
+		total := 0
+		for _, item := range val {
+			total += countValue(item)
+		}
+		return total
+	case map[string]any:
+		if isImagePart(val) {
+			return imageTokens
+		}
+		total := 0
+		for key, item := range val {
+			if key == "type" || key == "role" {
+				total++
+				continue
+			}
+			total += countValue(item)
+		}
+		return total
+	case nil:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// isImagePart reports whether a content part is an image, whose base64 data must not be counted as text.
+func isImagePart(part map[string]any) bool {
+	switch part["type"] {
+	case "image_url", "image", "input_image":
+		return true
+	}
+	if _, ok := part["inlineData"]; ok {
+		return true
+	}
+	if _, ok := part["inline_data"]; ok {
+		return true
+	}
+	return false
+}
+
+// EstimateMessage estimates the tokens of a single conversation turn, including its role overhead.
+func EstimateMessage(message any) int {
+	return messageOverhead + countValue(message)
+}
+
+// ContainsImage reports whether a JSON value holds an image part anywhere. Gemini inline data and
+// file references count only when their MIME type is an image.
+func ContainsImage(v any) bool {
+	switch val := v.(type) {
+	case []any:
+		for _, item := range val {
+			if ContainsImage(item) {
+				return true
+			}
+		}
+	case map[string]any:
+		switch val["type"] {
+		case "image_url", "image", "input_image":
+			return true
+		}
+		for _, field := range []string{"inlineData", "inline_data", "fileData", "file_data"} {
+			if data, ok := val[field].(map[string]any); ok && isImageData(data) {
+				return true
+			}
+		}
+		for _, item := range val {
+			if ContainsImage(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isImageData(data map[string]any) bool {
+	for _, field := range []string{"mimeType", "mime_type"} {
+		if mime, ok := data[field].(string); ok && strings.HasPrefix(mime, "image/") {
+			return true
+		}
+	}
+	return false
+}
@@ -24,6 +24,11 @@ type SystemSettings struct {
 	RequestLogRetentionDays        int    `json:"request_log_retention_days" default:"7" name:"config.log_retention_days" category:"config.category.basic" desc:"config.log_retention_days_desc" validate:"required,min=0"`
 	RequestLogWriteIntervalMinutes int    `json:"request_log_write_interval_minutes" default:"1" name:"config.log_write_interval" category:"config.category.basic" desc:"config.log_write_interval_desc" validate:"required,min=0"`
 	EnableRequestBodyLogging       bool   `json:"enable_request_body_logging" default:"false" name:"config.enable_request_body_logging" category:"config.category.basic" desc:"config.enable_request_body_logging_desc"`
+	ModelRoutes                    string `json:"model_routes" default:"" name:"config.model_routes" category:"config.category.basic" desc:"config.model_routes_desc"`
+	ModelPrices                    string `json:"model_prices" default:"" name:"config.model_prices" category:"config.category.basic" desc:"config.model_prices_desc"`
+	PriceMultiplierPercent         int    `json:"price_multiplier_percent" default:"100" name:"config.price_multiplier_percent" category:"config.category.basic" desc:"config.price_multiplier_percent_desc" validate:"min=0"`
+	IPAllowList                    string `json:"ip_allow_list" default:"" name:"config.ip_allow_list" category:"config.category.basic" desc:"config.ip_allow_list_desc" validate:"cidr_list"`
+	IPDenyList                     string `json:"ip_deny_list" default:"" name:"config.ip_deny_list" category:"config.category.basic" desc:"config.ip_deny_list_desc" validate:"cidr_list"`
 
 	// 请求设置
 	RequestTimeout        int    `json:"request_timeout" default:"600" name:"config.request_timeout" category:"config.category.request" desc:"config.request_timeout_desc" validate:"required,min=1"`
@@ -33,6 +38,7 @@ type SystemSettings struct {
 	MaxIdleConns          int    `json:"max_idle_conns" default:"100" name:"config.max_idle_conns" category:"config.category.request" desc:"config.max_idle_conns_desc" validate:"required,min=1"`
 	MaxIdleConnsPerHost   int    `json:"max_idle_conns_per_host" default:"50" name:"config.max_idle_conns_per_host" category:"config.category.request" desc:"config.max_idle_conns_per_host_desc" validate:"required,min=1"`
 	ProxyURL              string `json:"proxy_url" name:"config.proxy_url" category:"config.category.request" desc:"config.proxy_url_desc"`
+	MaxUploadSizeMB       int    `json:"max_upload_size_mb" default:"100" name:"config.max_upload_size_mb" category:"config.category.request" desc:"config.max_upload_size_mb_desc" validate:"min=0"`
 	MultimodalOnly        bool   `json:"multimodal_only" default:"false" name:"config.multimodal_only" category:"config.category.request" desc:"config.multimodal_only_desc"`
 	RemoveParams          string `json:"remove_params" default:"" name:"config.remove_params" category:"config.category.request" desc:"config.remove_params_desc"`
 	ToolsOverride         bool   `json:"tools_override" default:"false" name:"config.tools_override" category:"config.category.request" desc:"config.tools_override_desc"`
@@ -47,6 +53,15 @@ type SystemSettings struct {
 	ForceStreaming        bool   `json:"force_streaming" default:"false" name:"config.force_streaming" category:"config.category.request" desc:"config.force_streaming_desc"`
 	SystemPromptAppendText string `json:"system_prompt_append_text" default:"" name:"config.system_prompt_append_text" category:"config.category.request" desc:"config.system_prompt_append_text_desc"`
 	SystemPromptAppendMode string `json:"system_prompt_append_mode" default:"end" name:"config.system_prompt_append_mode" category:"config.category.request" desc:"config.system_prompt_append_mode_desc"`
+	ModelAliases          string `json:"model_aliases" default:"" name:"config.model_aliases" category:"config.category.request" desc:"config.model_aliases_desc"`
+	ModelAllowList        string `json:"model_allow_list" default:"" name:"config.model_allow_list" category:"config.category.request" desc:"config.model_allow_list_desc"`
+	StreamUsageInjection  bool   `json:"stream_usage_injection" default:"false" name:"config.stream_usage_injection" category:"config.category.request" desc:"config.stream_usage_injection_desc"`
+	MaxInputTokens        string `json:"max_input_tokens" default:"" name:"config.max_input_tokens" category:"config.category.request" desc:"config.max_input_tokens_desc"`
+	TruncationTokenBudget int    `json:"truncation_token_budget" default:"0" name:"config.truncation_token_budget" category:"config.category.request" desc:"config.truncation_token_budget_desc" validate:"min=0"`
+	TruncationKeepTurns   int    `json:"truncation_keep_turns" default:"6" name:"config.truncation_keep_turns" category:"config.category.request" desc:"config.truncation_keep_turns_desc" validate:"min=0"`
+	GroupMaxConcurrency   int    `json:"group_max_concurrency" default:"0" name:"config.group_max_concurrency" category:"config.category.request" desc:"config.group_max_concurrency_desc" validate:"min=0"`
+	GroupQueueSize        int    `json:"group_queue_size" default:"100" name:"config.group_queue_size" category:"config.category.request" desc:"config.group_queue_size_desc" validate:"min=0"`
+	GroupQueueTimeout     int    `json:"group_queue_timeout" default:"30" name:"config.group_queue_timeout" category:"config.category.request" desc:"config.group_queue_timeout_desc" validate:"min=0"`
 
 	// 密钥配置
 	MaxRetries                   int `json:"max_retries" default:"3" name:"config.max_retries" category:"config.category.key" desc:"config.max_retries_desc" validate:"required,min=0"`
@@ -54,6 +69,14 @@ type SystemSettings struct {
 	KeyValidationIntervalMinutes int `json:"key_validation_interval_minutes" default:"60" name:"config.key_validation_interval" category:"config.category.key" desc:"config.key_validation_interval_desc" validate:"required,min=1"`
 	KeyValidationConcurrency     int `json:"key_validation_concurrency" default:"10" name:"config.key_validation_concurrency" category:"config.category.key" desc:"config.key_validation_concurrency_desc" validate:"required,min=1"`
 	KeyValidationTimeoutSeconds  int `json:"key_validation_timeout_seconds" default:"20" name:"config.key_validation_timeout" category:"config.category.key" desc:"config.key_validation_timeout_desc" validate:"required,min=1"`
+	ResourceAffinityHours        int `json:"resource_affinity_hours" default:"720" name:"config.resource_affinity_hours" category:"config.category.key" desc:"config.resource_affinity_hours_desc" validate:"min=0"`
+	KeyWaitTimeout               int `json:"key_wait_timeout" default:"0" name:"config.key_wait_timeout" category:"config.category.key" desc:"config.key_wait_timeout_desc" validate:"min=0"`
+	SessionAffinityMinutes       int `json:"session_affinity_minutes" default:"0" name:"config.session_affinity_minutes" category:"config.category.key" desc:"config.session_affinity_minutes_desc" validate:"min=0"`
+	SessionAffinityHeader        string `json:"session_affinity_header" default:"X-Session-Id" name:"config.session_affinity_header" category:"config.category.key" desc:"config.session_affinity_header_desc"`
+	SessionAffinityMessages      int `json:"session_affinity_messages" default:"0" name:"config.session_affinity_messages" category:"config.category.key" desc:"config.session_affinity_messages_desc" validate:"min=0"`
+	KeyStrategy                  string `json:"key_strategy" default:"round_robin" name:"config.key_strategy" category:"config.category.key" desc:"config.key_strategy_desc" validate:"oneof=round_robin random least_recently_used least_in_flight fewest_failures"`
+	RespectUpstreamRateLimits    bool `json:"respect_upstream_rate_limits" default:"false" name:"config.respect_upstream_rate_limits" category:"config.category.key" desc:"config.respect_upstream_rate_limits_desc"`
+	RateLimitReservePercent      int `json:"rate_limit_reserve_percent" default:"5" name:"config.rate_limit_reserve_percent" category:"config.category.key" desc:"config.rate_limit_reserve_percent_desc" validate:"min=0"`
 
 	// For cache
 	ProxyKeysMap map[string]struct{} `json:"-"`
@@ -68,6 +91,8 @@ type ServerConfig struct {
 	WriteTimeout            int    `json:"write_timeout"`
 	IdleTimeout             int    `json:"idle_timeout"`
 	GracefulShutdownTimeout int    `json:"graceful_shutdown_timeout"`
+	// TrustedProxies lists the proxies whose X-Forwarded-For and X-Real-IP headers are honored.
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 // AuthConfig represents authentication configuration
@@ -10,6 +10,10 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// WebSocketProtocolKeyPrefix is the subprotocol prefix browser clients use to pass an API key,
+// since browsers cannot set the Authorization header on WebSocket handshakes.
+const WebSocketProtocolKeyPrefix = "openai-insecure-api-key."
+
 // HeaderVariableContext holds context data for variable resolution
 type HeaderVariableContext struct {
 	ClientIP string
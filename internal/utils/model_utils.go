@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SplitRules normalizes the separators accepted in list-style settings to commas and returns the trimmed entries.
+func SplitRules(s string) []string {
+	for _, sep := range []string{";", "|", " ", "\n", "\t"} {
+		s = strings.ReplaceAll(s, sep, ",")
+	}
+	return SplitAndTrim(s, ",")
+}
+
+// ParseModelAliases parses "alias:upstream_model" rules into an alias -> upstream model map.
+func ParseModelAliases(rules string) map[string]string {
+	aliases := make(map[string]string)
+	for _, rule := range SplitRules(rules) {
+		parts := strings.SplitN(rule, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		alias := strings.TrimSpace(parts[0])
+		target := strings.TrimSpace(parts[1])
+		if alias == "" || target == "" || alias == target {
+			continue
+		}
+		aliases[alias] = target
+	}
+	return aliases
+}
+
+// MatchModelPattern reports whether a model name matches a pattern where '*' matches any run of characters.
+// Matching is case-insensitive; '/' has no special meaning, so "models/*" and "*/gpt-4o" work as expected.
+func MatchModelPattern(pattern, model string) bool {
+	pattern = strings.ToLower(pattern)
+	model = strings.ToLower(model)
+
+	if !strings.Contains(pattern, "*") {
+		return pattern == model
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(model, parts[0]) {
+		return false
+	}
+	model = model[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(model, part)
+		if idx < 0 {
+			return false
+		}
+		model = model[idx+len(part):]
+	}
+	return strings.HasSuffix(model, last)
+}
+
+// MatchAnyModelPattern reports whether the model matches at least one of the patterns.
+func MatchAnyModelPattern(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if MatchModelPattern(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsModelAllowed checks a model against an allow-list setting. An empty list allows every model.
+func IsModelAllowed(allowList string, model string) bool {
+	patterns := SplitRules(allowList)
+	if len(patterns) == 0 {
+		return true
+	}
+	return MatchAnyModelPattern(patterns, model)
+}
+
+// ModelPrice holds the USD prices per million tokens of the models matching Pattern.
+type ModelPrice struct {
+	Pattern     string
+	Input       float64
+	Output      float64
+	CachedInput float64
+}
+
+// ParseModelPrices parses "pattern:input/output[/cached_input]" rules, keeping their order so earlier rules take precedence.
+// The cached input price defaults to the input price.
+func ParseModelPrices(rules string) []ModelPrice {
+	var prices []ModelPrice
+	for _, rule := range SplitRules(rules) {
+		idx := strings.LastIndex(rule, ":")
+		if idx <= 0 {
+			continue
+		}
+		values := strings.Split(rule[idx+1:], "/")
+		if len(values) < 2 || len(values) > 3 {
+			continue
+		}
+
+		var parsed [3]float64
+		valid := true
+		for i, v := range values {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || f < 0 {
+				valid = false
+				break
+			}
+			parsed[i] = f
+		}
+		if !valid {
+			continue
+		}
+		if len(values) == 2 {
+			parsed[2] = parsed[0]
+		}
+
+		prices = append(prices, ModelPrice{
+			Pattern:     strings.TrimSpace(rule[:idx]),
+			Input:       parsed[0],
+			Output:      parsed[1],
+			CachedInput: parsed[2],
+		})
+	}
+	return prices
+}
+
+// FindModelPrice returns the first price rule matching the model.
+func FindModelPrice(prices []ModelPrice, model string) (ModelPrice, bool) {
+	for _, price := range prices {
+		if MatchModelPattern(price.Pattern, model) {
+			return price, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// ModelLimit caps a numeric value for the models matching Pattern.
+type ModelLimit struct {
+	Pattern string
+	Limit   int
+}
+
+// ParseModelLimits parses "pattern:limit" rules, keeping their order so earlier rules take precedence.
+func ParseModelLimits(rules string) []ModelLimit {
+	var limits []ModelLimit
+	for _, rule := range SplitRules(rules) {
+		idx := strings.LastIndex(rule, ":")
+		if idx <= 0 {
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(rule[idx+1:]))
+		if err != nil || limit <= 0 {
+			continue
+		}
+		limits = append(limits, ModelLimit{Pattern: strings.TrimSpace(rule[:idx]), Limit: limit})
+	}
+	return limits
+}
+
+// FindModelLimit returns the limit of the first rule matching the model.
+func FindModelLimit(limits []ModelLimit, model string) (int, bool) {
+	for _, limit := range limits {
+		if MatchModelPattern(limit.Pattern, model) {
+			return limit.Limit, true
+		}
+	}
+	return 0, false
+}
+
+// ModelRoute maps a model name pattern to the group that serves it.
+type ModelRoute struct {
+	Pattern string
+	Group   string
+}
+
+// ParseModelRoutes parses "pattern=group" rules, keeping their order so earlier rules take precedence.
+func ParseModelRoutes(rules string) []ModelRoute {
+	var routes []ModelRoute
+	for _, rule := range SplitRules(rules) {
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		pattern := strings.TrimSpace(parts[0])
+		group := strings.TrimSpace(parts[1])
+		if pattern == "" || group == "" {
+			continue
+		}
+		routes = append(routes, ModelRoute{Pattern: pattern, Group: group})
+	}
+	return routes
+}
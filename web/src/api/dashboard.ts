@@ -8,13 +8,16 @@ export const getDashboardStats = () => {
   return http.get<DashboardStatsResponse>("/dashboard/stats");
 };
 
+export type ChartMetric = "requests" | "tokens" | "cost" | "latency";
+
 /**
  * 获取仪表盘图表数据
  * @param groupId 可选的分组ID
+ * @param metric 图表指标，默认为请求数
  */
-export const getDashboardChart = (groupId?: number) => {
+export const getDashboardChart = (groupId?: number, metric: ChartMetric = "requests") => {
   return http.get<ChartData>("/dashboard/chart", {
-    params: groupId ? { groupId } : {},
+    params: { ...(groupId ? { groupId } : {}), metric },
   });
 };
 
@@ -74,6 +74,7 @@ export const keysApi = {
     page_size: number;
     key_value?: string;
     status?: KeyStatus;
+    label?: string;
   }): Promise<{
     items: APIKey[];
     pagination: {
@@ -176,6 +177,41 @@ export const keysApi = {
     });
   },
 
+  // 设置密钥状态
+  updateKeyStatus(
+    group_id: number,
+    keys_text: string,
+    status: Exclude<KeyStatus, "invalid" | undefined>,
+    cooldown_minutes?: number
+  ): Promise<null> {
+    return http.post("/keys/update-status", {
+      group_id,
+      keys_text,
+      status,
+      cooldown_minutes,
+    });
+  },
+
+  // 设置密钥名称、备注、标签、来源和有效期
+  updateKeyMetadata(
+    group_id: number,
+    keys_text: string,
+    metadata: {
+      name?: string;
+      notes?: string;
+      labels?: string[];
+      source?: string;
+      expires_at?: string;
+      clear_expiry?: boolean;
+    }
+  ): Promise<null> {
+    return http.post("/keys/update-metadata", {
+      group_id,
+      keys_text,
+      ...metadata,
+    });
+  },
+
   // 恢复所有无效密钥
   restoreAllInvalidKeys(group_id: number): Promise<void> {
     return http.post("/keys/restore-all-invalid", { group_id });
@@ -204,7 +240,7 @@ export const keysApi = {
   },
 
   // 导出密钥
-  exportKeys(groupId: number, status: "all" | "active" | "invalid" = "all"): void {
+  exportKeys(groupId: number, status: "all" | "active" | "invalid" = "all", label?: string): void {
     const authKey = localStorage.getItem("authKey");
     if (!authKey) {
       window.$message.error(i18n.global.t("auth.noAuthKeyFound"));
@@ -219,6 +255,9 @@ export const keysApi = {
     if (status !== "all") {
       params.append("status", status);
     }
+    if (label) {
+      params.append("label", label);
+    }
 
     const url = `${http.defaults.baseURL}/keys/export?${params.toString()}`;
 
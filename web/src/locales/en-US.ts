@@ -162,6 +162,10 @@ export default {
     blacklistCount: "Blacklist Count",
     valid: "Valid",
     invalid: "Invalid",
+    disabled: "Disabled",
+    cooldown: "Cooldown",
+    quotaExhausted: "No Quota",
+    expired: "Expired",
     checking: "Checking",
     unchecked: "Unchecked",
     addToBlacklist: "Add to Blacklist",
@@ -199,6 +203,8 @@ export default {
     testFailed: "Key test failed: Invalid API key",
     restoreKey: "Restore Key",
     confirmRestoreKey: 'Are you sure to restore key "{key}"?',
+    disableKey: "Disable Key",
+    confirmDisableKey: 'Are you sure to disable key "{key}"? It will not be restored automatically.',
     confirmDeleteKey: 'Are you sure to delete key "{key}"?',
     restoreSuccess: "Restore successful",
     validatingKeys: "Validating {type} keys...",
@@ -292,6 +298,11 @@ export default {
     paramOverrides: "Parameter Overrides",
     systemPromptModeFront: "Prepend to system prompt",
     systemPromptModeEnd: "Append to system prompt",
+    keyStrategyRoundRobin: "Round-robin (by weight)",
+    keyStrategyRandom: "Random (by weight)",
+    keyStrategyLeastRecentlyUsed: "Least recently used",
+    keyStrategyLeastInFlight: "Fewest in-flight requests",
+    keyStrategyFewestFailures: "Fewest recent failures",
     enterModelName: "Enter model name",
     enterUpstreamUrl: "Enter upstream URL",
     enterValidationPath: "Enter validation endpoint path",
@@ -317,7 +328,7 @@ export default {
     testPathTooltip2: "If using non-standard path, please enter complete API path here",
     optionalCustomValidationPath: "Optional, custom API path for key validation",
     proxyKeysTooltip:
-      "Group-specific proxy keys for accessing this group's proxy endpoint. Separate multiple keys with commas.",
+      "Group-specific proxy keys for accessing this group's proxy endpoint. Separate multiple keys with commas. They are imported into the proxy key list on startup and whenever keys are added here; once that list has keys, only keys in it are accepted, so removing a key here does not revoke it.",
     proxyKeysCopied: "Proxy keys copied to clipboard",
     multiKeysPlaceholder: "Separate multiple keys with commas",
     descriptionTooltip:
@@ -384,6 +395,7 @@ export default {
     clearAllKeysSuccess: "All keys cleared successfully",
     allStatus: "All Status",
     keyExactMatch: "Key exact match",
+    labelFilter: "Label",
     searchByName: "Search by name...",
     noMatchingKeys: "No matching keys found",
     showHide: "Show/Hide",
@@ -394,8 +406,12 @@ export default {
     restore: "Restore",
     requestsShort: "RQ",
     failuresShort: "FL",
+    weightShort: "WT",
+    priorityShort: "PR",
+    expiresShort: "EXP",
     testShort: "Go",
     restoreShort: "↻",
+    disableShort: "⏸",
     validShort: "OK",
     invalidShort: "NG",
     testKey: "Test Key",
@@ -449,6 +465,9 @@ export default {
     statusCode: "Status Code",
     duration: "Duration(ms)",
     model: "Model",
+    tokens: "Tokens (In / Out)",
+    truncatedMessages: "Truncated Messages",
+    proxyKey: "Proxy Key",
     sourceIP: "Source IP",
     groupName: "Group Name",
     parentGroup: "Aggregate Group",
@@ -551,6 +570,10 @@ export default {
   charts: {
     requestTrend24h: "24h Request Trend",
     allGroups: "All Groups",
+    metricRequests: "Requests",
+    metricTokens: "Tokens",
+    metricCost: "Cost",
+    metricLatency: "Latency",
   },
   security: {
     warningsWithHigh:
@@ -161,6 +161,10 @@ export default {
     blacklistCount: "ブラックリスト回数",
     valid: "有効",
     invalid: "無効",
+    disabled: "無効化済み",
+    cooldown: "クールダウン中",
+    quotaExhausted: "残高不足",
+    expired: "期限切れ",
     checking: "チェック中",
     unchecked: "未チェック",
     addToBlacklist: "ブラックリストに追加",
@@ -198,6 +202,8 @@ export default {
     testFailed: "キーテスト失敗: 無効なAPIキー",
     restoreKey: "キーを復元",
     confirmRestoreKey: 'キー"{key}"を復元してもよろしいですか？',
+    disableKey: "キーを無効化",
+    confirmDisableKey: 'キー"{key}"を無効化してもよろしいですか？自動では復元されません。',
     confirmDeleteKey: 'キー"{key}"を削除してもよろしいですか？',
     restoreSuccess: "復元成功",
     validatingKeys: "{type}キーを検証中...",
@@ -291,6 +297,11 @@ export default {
     paramOverrides: "パラメーターオーバーライド",
     systemPromptModeFront: "システムプロンプトの先頭に追加",
     systemPromptModeEnd: "システムプロンプトの末尾に追加",
+    keyStrategyRoundRobin: "重み付きラウンドロビン",
+    keyStrategyRandom: "重み付きランダム",
+    keyStrategyLeastRecentlyUsed: "最も長く未使用",
+    keyStrategyLeastInFlight: "処理中リクエストが最少",
+    keyStrategyFewestFailures: "最近の失敗が最少",
     enterModelName: "モデル名を入力してください",
     enterUpstreamUrl: "アップストリームURLを入力してください",
     enterValidationPath: "検証エンドポイントパスを入力してください",
@@ -316,7 +327,7 @@ export default {
     testPathTooltip2: "非標準パスを使用する場合は、完全なAPIパスをここに入力してください",
     optionalCustomValidationPath: "オプション、キー検証用のカスタムAPIパス",
     proxyKeysTooltip:
-      "このグループのプロキシエンドポイントにアクセスするためのグループ固有のプロキシキー。複数のキーはカンマで区切ってください。",
+      "このグループのプロキシエンドポイントにアクセスするためのグループ固有のプロキシキー。複数のキーはカンマで区切ってください。起動時およびここでキーを追加したときにプロキシキー一覧へ取り込まれます。一覧にキーがある間は一覧のキーのみが受け付けられるため、ここでキーを削除しても無効にはなりません。",
     proxyKeysCopied: "プロキシキーがクリップボードにコピーされました",
     multiKeysPlaceholder: "複数のキーはカンマで区切ってください",
     descriptionTooltip:
@@ -383,6 +394,7 @@ export default {
     clearAllKeysSuccess: "すべてのキーが正常にクリアされました",
     allStatus: "すべてのステータス",
     keyExactMatch: "キー完全一致",
+    labelFilter: "ラベル",
     searchByName: "名前で検索...",
     noMatchingKeys: "一致するキーが見つかりません",
     showHide: "表示/非表示",
@@ -393,8 +405,12 @@ export default {
     restore: "復元",
     requestsShort: "要求",
     failuresShort: "失敗",
+    weightShort: "重み",
+    priorityShort: "優先度",
+    expiresShort: "期限",
     testShort: "試験",
     restoreShort: "復元",
+    disableShort: "⏸",
     validShort: "有効",
     invalidShort: "無効",
     testKey: "キーをテスト",
@@ -449,6 +465,9 @@ export default {
     statusCode: "ステータスコード",
     duration: "所要時間(ms)",
     model: "モデル",
+    tokens: "トークン（入力 / 出力）",
+    truncatedMessages: "切り詰められたメッセージ",
+    proxyKey: "プロキシキー",
     sourceIP: "ソースIP",
     groupName: "グループ名",
     parentGroup: "集約グループ",
@@ -548,6 +567,10 @@ export default {
   charts: {
     requestTrend24h: "24時間リクエストトレンド",
     allGroups: "すべてのグループ",
+    metricRequests: "リクエスト数",
+    metricTokens: "トークン",
+    metricCost: "コスト",
+    metricLatency: "レイテンシ",
   },
   security: {
     warningsWithHigh:
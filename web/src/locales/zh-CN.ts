@@ -160,6 +160,10 @@ export default {
     blacklistCount: "黑名单次数",
     valid: "有效",
     invalid: "无效",
+    disabled: "已停用",
+    cooldown: "冷却中",
+    quotaExhausted: "额度耗尽",
+    expired: "已过期",
     checking: "检查中",
     unchecked: "未检查",
     addToBlacklist: "加入黑名单",
@@ -197,6 +201,8 @@ export default {
     testFailed: "密钥测试失败: 无效的API密钥",
     restoreKey: "恢复密钥",
     confirmRestoreKey: '确定要恢复密钥"{key}"吗？',
+    disableKey: "停用密钥",
+    confirmDisableKey: '确定要停用密钥"{key}"吗？停用后不会被自动恢复。',
     confirmDeleteKey: '确定要删除密钥"{key}"吗？',
     restoreSuccess: "恢复成功",
     validatingKeys: "正在验证{type}密钥...",
@@ -287,6 +293,11 @@ export default {
     paramOverrides: "参数覆盖",
     systemPromptModeFront: "追加到 System Prompt 开头",
     systemPromptModeEnd: "追加到 System Prompt 末尾",
+    keyStrategyRoundRobin: "按权重轮询",
+    keyStrategyRandom: "按权重随机",
+    keyStrategyLeastRecentlyUsed: "最久未使用",
+    keyStrategyLeastInFlight: "进行中请求最少",
+    keyStrategyFewestFailures: "近期失败最少",
     enterModelName: "请输入模型名称",
     enterUpstreamUrl: "请输入上游地址",
     enterValidationPath: "请输入验证端点路径",
@@ -310,7 +321,7 @@ export default {
     testPathTooltip1: "自定义用于验证密钥的API端点路径。如果不填写，将使用默认路径",
     testPathTooltip2: "如需使用非标准路径，请在此填写完整的API路径",
     optionalCustomValidationPath: "可选，自定义用于验证key的API路径",
-    proxyKeysTooltip: "分组专用代理密钥，用于访问此分组的代理端点。多个密钥请用逗号分隔。",
+    proxyKeysTooltip: "分组专用代理密钥，用于访问此分组的代理端点。多个密钥请用逗号分隔。启动时以及在这里新增密钥时会导入代理密钥列表；列表中有密钥后只接受列表中的密钥，因此在这里删除密钥不会使其失效。",
     proxyKeysCopied: "代理密钥已复制到剪贴板",
     multiKeysPlaceholder: "多个密钥请用英文逗号 , 分隔",
     descriptionTooltip: "分组的详细说明，帮助团队成员了解该分组的用途和特点。支持多行文本",
@@ -371,6 +382,7 @@ export default {
     clearAllKeysSuccess: "已成功清空所有密钥",
     allStatus: "全部状态",
     keyExactMatch: "Key 精确匹配",
+    labelFilter: "标签",
     searchByName: "搜索分组名称...",
     noMatchingKeys: "没有找到匹配的密钥",
     showHide: "显示/隐藏",
@@ -381,8 +393,12 @@ export default {
     restore: "恢复",
     requestsShort: "请求",
     failuresShort: "失败",
+    weightShort: "权重",
+    priorityShort: "优先级",
+    expiresShort: "到期",
     testShort: "测试",
     restoreShort: "恢复",
+    disableShort: "⏸",
     validShort: "有效",
     invalidShort: "无效",
     testKey: "测试密钥",
@@ -436,6 +452,9 @@ export default {
     statusCode: "状态码",
     duration: "耗时(ms)",
     model: "模型",
+    tokens: "Token（输入 / 输出）",
+    truncatedMessages: "截断消息数",
+    proxyKey: "代理密钥",
     sourceIP: "源IP",
     groupName: "分组名",
     parentGroup: "聚合分组",
@@ -538,6 +557,10 @@ export default {
   charts: {
     requestTrend24h: "24小时请求趋势",
     allGroups: "全部分组",
+    metricRequests: "请求数",
+    metricTokens: "Token",
+    metricCost: "费用",
+    metricLatency: "耗时",
   },
   security: {
     warningsWithHigh: "发现 {count} 个安全配置问题，{highCount} 个需要优先处理",
@@ -6,7 +6,17 @@ export interface ApiResponse<T> {
 }
 
 // 密钥状态
-export type KeyStatus = "active" | "invalid" | undefined;
+export type KeyStatus =
+  | "active"
+  | "invalid"
+  | "disabled"
+  | "cooldown"
+  | "quota_exhausted"
+  | "expired"
+  | undefined;
+
+// 除 active 和 invalid 外各状态的密钥数量
+export type KeyStatusCounts = Partial<Record<NonNullable<KeyStatus>, number>>;
 
 // 分组类型
 export type GroupType = "standard" | "aggregate";
@@ -22,6 +32,14 @@ export interface APIKey {
   status: KeyStatus;
   request_count: number;
   failure_count: number;
+  weight: number;
+  priority: number;
+  cooldown_until?: string | null;
+  name: string;
+  notes: string;
+  labels: string[] | null;
+  source: string;
+  expires_at?: string | null;
   last_used_at?: string;
   created_at: string;
   updated_at: string;
@@ -51,6 +69,7 @@ export interface SubGroupInfo {
   total_keys: number;
   active_keys: number;
   invalid_keys: number;
+  key_status_counts?: KeyStatusCounts;
 }
 
 // 父聚合分组信息（展示时使用）
@@ -104,6 +123,7 @@ export interface KeyStats {
   total_keys: number;
   active_keys: number;
   invalid_keys: number;
+  key_status_counts?: KeyStatusCounts;
 }
 
 // RequestStats defines the statistics for requests over a period.
@@ -164,6 +184,12 @@ export interface RequestLog {
   upstream_addr: string;
   is_stream: boolean;
   request_body?: string;
+  prompt_tokens: number;
+  completion_tokens: number;
+  cached_tokens: number;
+  reasoning_tokens: number;
+  truncated_messages: number;
+  proxy_key_id: number;
 }
 
 export interface Pagination {
@@ -184,6 +210,7 @@ export interface LogFilter {
   group_name?: string;
   parent_group_name?: string;
   key_value?: string;
+  proxy_key_name?: string;
   model?: string;
   is_success?: boolean | null;
   status_code?: number | null;
@@ -229,6 +256,8 @@ export interface DashboardStatsResponse {
   rpm: StatCard;
   request_count: StatCard;
   error_rate: StatCard;
+  cost: StatCard;
+  key_status_counts?: KeyStatusCounts;
   security_warnings: SecurityWarning[];
 }
 
@@ -1,4 +1,4 @@
-import type { Group, SubGroupInfo } from "@/types/models";
+import type { Group, KeyStatusCounts, SubGroupInfo } from "@/types/models";
 
 const CHANNEL_TYPE_LABELS: Record<string, string> = {
   openai: "OpenAI",
@@ -83,3 +83,28 @@ export function maskProxyKeys(keys: string): string {
     .map(key => maskKey(key.trim()))
     .join(", ");
 }
+
+const OTHER_KEY_STATUS_LABELS = {
+  disabled: "keys.disabled",
+  cooldown: "keys.cooldown",
+  quota_exhausted: "keys.quotaExhausted",
+  expired: "keys.expired",
+} as const;
+
+/**
+ * Lists the non-zero counts of the inactive key statuses other than invalid, with their labels.
+ *
+ * @param counts The key_status_counts of a stats response.
+ * @param t The i18n translate function.
+ */
+export function getOtherKeyStatusCounts(
+  counts: KeyStatusCounts | undefined,
+  t: (key: string) => string
+): { label: string; count: number }[] {
+  return Object.entries(OTHER_KEY_STATUS_LABELS)
+    .map(([status, label]) => ({
+      label: t(label),
+      count: counts?.[status as keyof typeof OTHER_KEY_STATUS_LABELS] ?? 0,
+    }))
+    .filter(item => item.count > 0);
+}